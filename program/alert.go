@@ -0,0 +1,453 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/keilerkonzept/topk/heap"
+)
+
+// Alert rule kinds.
+const (
+	alertCount      = "count"       // count>N: an item's window count exceeds N
+	alertRise       = "rise"        // rise>Z: an item's trend score exceeds Z
+	alertEnterTop   = "enter-top"   // enter-top=N: an item enters the top N
+	alertTopChanged = "top-changed" // the top-1 item changed
+)
+
+type alertRule struct {
+	spec  string
+	kind  string
+	limit float64
+}
+
+func parseAlertRule(spec string) (alertRule, error) {
+	spec = strings.TrimSpace(spec)
+	r := alertRule{spec: spec}
+	if spec == alertTopChanged {
+		r.kind = alertTopChanged
+		return r, nil
+	}
+	if name, arg, ok := strings.Cut(spec, ">"); ok && (name == alertCount || name == alertRise) {
+		limit, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			return r, flagErrorf("alert", "%q: invalid limit %q", spec, arg)
+		}
+		r.kind, r.limit = name, limit
+		return r, nil
+	}
+	if name, arg, ok := strings.Cut(spec, "="); ok && name == alertEnterTop {
+		n, err := strconv.Atoi(arg)
+		if err != nil || n < 1 {
+			return r, flagErrorf("alert", "%q: N must be >= 1", spec)
+		}
+		r.kind, r.limit = name, float64(n)
+		return r, nil
+	}
+	return r, flagErrorf("alert", "%q: unknown rule (use count>N, rise>Z, enter-top=N or top-changed)", spec)
+}
+
+// alert is a fired rule, as delivered to sinks.
+type alert struct {
+	Time      time.Time `json:"time"`
+	EventTime time.Time `json:"event_time"`
+	Rule      string    `json:"rule"`
+	Item      string    `json:"item"`
+	Count     uint32    `json:"count"`
+	Rank      int       `json:"rank"`
+	Message   string    `json:"message"`
+}
+
+func (a alert) json() ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	// Rules contain '>'.
+	enc.SetEscapeHTML(false)
+	err := enc.Encode(a)
+	return buf.Bytes(), err
+}
+
+func (a alert) String() string {
+	return fmt.Sprintf("%s ALERT %s: %s", a.Time.UTC().Format(time.RFC3339), a.Rule, a.Message)
+}
+
+// alertSink delivers alerts to one destination:
+//
+//	stderr            one line per alert
+//	file=PATH         append one JSON object per line
+//	exec=COMMAND      run COMMAND in the shell with the alert as JSON on stdin
+//	webhook=URL       POST the alert as JSON
+type alertSink struct {
+	spec  string
+	send  func(alert) error
+	close func() error
+	queue chan alert // alerts waiting for this sink
+}
+
+const webhookTimeout = 5 * time.Second
+
+func parseAlertSink(spec string) (name, arg string, err error) {
+	name, arg, hasArg := strings.Cut(strings.TrimSpace(spec), "=")
+	switch name {
+	case "stderr":
+		if hasArg {
+			return "", "", flagErrorf("alert-sink", "%q: stderr takes no argument", spec)
+		}
+	case "file", "exec", "webhook":
+		if arg == "" {
+			return "", "", flagErrorf("alert-sink", "%q: %s needs a value", spec, name)
+		}
+	default:
+		return "", "", flagErrorf("alert-sink", "%q: unknown sink (use stderr, file=PATH, exec=COMMAND or webhook=URL)", spec)
+	}
+	return name, arg, nil
+}
+
+func openAlertSink(spec string) (*alertSink, error) {
+	name, arg, err := parseAlertSink(spec)
+	if err != nil {
+		return nil, err
+	}
+	s := &alertSink{spec: spec, close: func() error { return nil }}
+	switch name {
+	case "stderr":
+		s.send = func(a alert) error {
+			_, err := fmt.Fprintln(os.Stderr, a)
+			return err
+		}
+	case "file":
+		f, err := os.OpenFile(arg, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("alert sink %s: %w", spec, err)
+		}
+		enc := json.NewEncoder(f)
+		enc.SetEscapeHTML(false)
+		s.send = func(a alert) error { return enc.Encode(a) }
+		s.close = f.Close
+	case "exec":
+		s.send = func(a alert) error { return runAlertCommand(arg, a) }
+	case "webhook":
+		client := &http.Client{Timeout: webhookTimeout}
+		s.send = func(a alert) error { return postAlert(client, arg, a) }
+	}
+	return s, nil
+}
+
+// runAlertCommand runs command with the alert as JSON on stdin and its main
+// fields in LOGSPEED_ALERT_* environment variables.
+func runAlertCommand(command string, a alert) error {
+	cmd := exec.Command("sh", "-c", command)
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	}
+	body, err := a.json()
+	if err != nil {
+		return err
+	}
+	cmd.Stdin = bytes.NewReader(body)
+	cmd.Env = append(os.Environ(),
+		"LOGSPEED_ALERT_RULE="+a.Rule,
+		"LOGSPEED_ALERT_ITEM="+a.Item,
+		"LOGSPEED_ALERT_COUNT="+strconv.FormatUint(uint64(a.Count), 10),
+		"LOGSPEED_ALERT_MESSAGE="+a.Message,
+	)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%w: %s", err, bytes.TrimSpace(out))
+	}
+	return nil
+}
+
+func postAlert(client *http.Client, url string, a alert) error {
+	body, err := a.json()
+	if err != nil {
+		return err
+	}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	return nil
+}
+
+// alertState tracks one condition, a rule for a given item.
+type alertState struct {
+	holds bool      // the condition held at the last evaluation
+	fired time.Time // when it last fired
+	last  alert
+}
+
+// alerter evaluates the alert rules on every leaderboard refresh and delivers
+// fired alerts in the background, one goroutine per sink so that a slow sink
+// doesn't hold up the others. A condition fires when it
+// starts to hold, unless it already fired within -alert-cooldown; it stays
+// active while it holds or for the cooldown after firing.
+type alerter struct {
+	rules    []alertRule
+	sinks    []*alertSink
+	cooldown time.Duration
+
+	state   map[string]*alertState
+	prevTop []string // previous top items by count, most frequent first
+
+	done    chan struct{} // closed once every sink is drained and closed
+	dropped atomic.Uint64
+	onError func(error)
+}
+
+const alertQueueSize = 256
+
+// newAlerter compiles rules and opens sinks. State from prev is kept, so that
+// a config reload doesn't fire every active condition again.
+func newAlerter(rules, sinks []string, cooldown time.Duration, prev *alerter, onError func(error)) (*alerter, error) {
+	if len(rules) == 0 {
+		return nil, nil
+	}
+	a := &alerter{
+		cooldown: cooldown,
+		state:    make(map[string]*alertState),
+		done:     make(chan struct{}),
+		onError:  onError,
+	}
+	for _, spec := range rules {
+		r, err := parseAlertRule(spec)
+		if err != nil {
+			return nil, err
+		}
+		a.rules = append(a.rules, r)
+	}
+	for _, spec := range sinks {
+		s, err := openAlertSink(spec)
+		if err != nil {
+			a.closeSinks()
+			return nil, err
+		}
+		a.sinks = append(a.sinks, s)
+	}
+	if prev != nil {
+		a.state, a.prevTop = prev.state, prev.prevTop
+	}
+	var wg sync.WaitGroup
+	for _, s := range a.sinks {
+		s.queue = make(chan alert, alertQueueSize)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			a.deliver(s)
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(a.done)
+	}()
+	return a, nil
+}
+
+// deliver sends the alerts queued for s until the queue is closed, and then
+// closes s.
+func (a *alerter) deliver(s *alertSink) {
+	defer s.close()
+	for al := range s.queue {
+		if err := s.send(al); err != nil && a.onError != nil {
+			a.onError(fmt.Errorf("alert sink %s: %w", s.spec, err))
+		}
+	}
+}
+
+// alertDrainTimeout bounds how long close waits for queued alerts.
+const alertDrainTimeout = 2 * time.Second
+
+// close stops queueing and waits for the sinks to deliver the queued alerts.
+// A sink that is still busy after alertDrainTimeout is closed by its own
+// goroutine once it finishes.
+func (a *alerter) close() {
+	if a == nil {
+		return
+	}
+	for _, s := range a.sinks {
+		close(s.queue)
+	}
+	select {
+	case <-a.done:
+	case <-time.After(alertDrainTimeout):
+	}
+}
+
+// closeSinks closes sinks that have no delivery goroutine yet.
+func (a *alerter) closeSinks() {
+	for _, s := range a.sinks {
+		s.close()
+	}
+}
+
+// evaluate checks the rules against items, sorted by count, and queues the
+// alerts that fire.
+func (a *alerter) evaluate(now, eventTime time.Time, items []heap.Item, trends map[string]trend) {
+	if a == nil {
+		return
+	}
+	holding := make(map[string]bool)
+	check := func(r alertRule, item heap.Item, rank int, msg string) {
+		key := r.spec + "\x00" + item.Item
+		holding[key] = true
+		st := a.state[key]
+		if st == nil {
+			st = &alertState{}
+			a.state[key] = st
+		}
+		if !st.holds && (st.fired.IsZero() || now.Sub(st.fired) >= a.cooldown) {
+			st.fired = now
+			st.last = alert{
+				Time:      now,
+				EventTime: eventTime,
+				Rule:      r.spec,
+				Item:      item.Item,
+				Count:     item.Count,
+				Rank:      rank,
+				Message:   msg,
+			}
+			for _, s := range a.sinks {
+				select {
+				case s.queue <- st.last:
+				default:
+					a.dropped.Add(1)
+				}
+			}
+		}
+		st.holds = true
+	}
+
+	for _, r := range a.rules {
+		switch r.kind {
+		case alertCount:
+			for i, item := range items {
+				if float64(item.Count) > r.limit {
+					check(r, item, i+1, fmt.Sprintf("%s has %d in window (> %g)", item.Item, item.Count, r.limit))
+				}
+			}
+		case alertRise:
+			for i, item := range items {
+				if score := trends[item.Item].score; score > r.limit {
+					check(r, item, i+1, fmt.Sprintf("%s is rising at %.1fσ (> %g)", item.Item, score, r.limit))
+				}
+			}
+		case alertEnterTop:
+			if len(a.prevTop) == 0 {
+				// Nothing to compare with yet.
+				break
+			}
+			n := int(r.limit)
+			for i, item := range items[:min(n, len(items))] {
+				if !containsItem(a.prevTop[:min(n, len(a.prevTop))], item.Item) {
+					check(r, item, i+1, fmt.Sprintf("%s entered the top %d at #%d", item.Item, n, i+1))
+				}
+			}
+		case alertTopChanged:
+			if len(a.prevTop) > 0 && len(items) > 0 && items[0].Item != a.prevTop[0] {
+				check(r, items[0], 1, fmt.Sprintf("%s replaced %s as top-1", items[0].Item, a.prevTop[0]))
+			}
+		}
+	}
+
+	for key, st := range a.state {
+		st.holds = holding[key]
+		if !st.holds && now.Sub(st.fired) >= a.cooldown {
+			delete(a.state, key)
+		}
+	}
+	a.prevTop = a.prevTop[:0]
+	for _, item := range items {
+		a.prevTop = append(a.prevTop, item.Item)
+	}
+}
+
+func containsItem(items []string, item string) bool {
+	for _, it := range items {
+		if it == item {
+			return true
+		}
+	}
+	return false
+}
+
+// active returns the alerts whose condition holds or that fired within the
+// cooldown, most recent first.
+func (a *alerter) active(now time.Time) []alert {
+	if a == nil {
+		return nil
+	}
+	var active []alert
+	for _, st := range a.state {
+		if st.holds || now.Sub(st.fired) < a.cooldown {
+			active = append(active, st.last)
+		}
+	}
+	sort.Slice(active, func(i, j int) bool {
+		if !active[i].Time.Equal(active[j].Time) {
+			return active[i].Time.After(active[j].Time)
+		}
+		return active[i].Rank < active[j].Rank
+	})
+	return active
+}
+
+// alertLine is the TUI line for the active alerts, cut to width w.
+func (a *alerter) alertLine(now time.Time, w int) string {
+	active := a.active(now)
+	if len(active) == 0 {
+		return "alerts: none active"
+	}
+	line := fmt.Sprintf("ALERTS (%d):", len(active))
+	for i, al := range active {
+		part := " " + al.Rule + " " + al.Message
+		if i > 0 {
+			part = ";" + part
+		}
+		if more := fmt.Sprintf("; +%d more", len(active)-i); i > 0 && len(line)+len(part)+len(more) > w {
+			line += more
+			break
+		}
+		line += part
+	}
+	if dropped := a.dropped.Load(); dropped > 0 {
+		line += fmt.Sprintf(" (%d undelivered)", dropped)
+	}
+	return cutLine(line, w)
+}
+
+// alertsChanged reports whether the alert settings differ between two configs.
+func alertsChanged(a, b *Config) bool {
+	return a.Alerts.String() != b.Alerts.String() ||
+		a.AlertSinks.String() != b.AlertSinks.String() ||
+		a.AlertCooldown != b.AlertCooldown
+}
+
+// evaluateAlerts checks the alert rules against the refreshed leaderboard.
+func (m *model) evaluateAlerts(now time.Time) {
+	if m.alerts == nil {
+		return
+	}
+	items, eventTime := m.rankedItems()
+	m.mu.Lock()
+	trends := m.trends
+	m.mu.Unlock()
+	m.alerts.evaluate(now, eventTime, items, trends)
+}
+
+func (m *model) alertError(err error) {
+	m.setNotice(err.Error())
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/keilerkonzept/topk/heap"
+)
+
+func TestParseAlertRule(t *testing.T) {
+	tests := []struct {
+		spec  string
+		kind  string
+		limit float64
+		err   bool
+	}{
+		{spec: "count>5000", kind: alertCount, limit: 5000},
+		{spec: " count>2.5 ", kind: alertCount, limit: 2.5},
+		{spec: "rise>3", kind: alertRise, limit: 3},
+		{spec: "enter-top=5", kind: alertEnterTop, limit: 5},
+		{spec: "top-changed", kind: alertTopChanged},
+		{spec: "count>many", err: true},
+		{spec: "rise>", err: true},
+		{spec: "enter-top=0", err: true},
+		{spec: "enter-top=x", err: true},
+		{spec: "count=5", err: true},
+		{spec: "top-changed>1", err: true},
+		{spec: "", err: true},
+	}
+	for _, tt := range tests {
+		r, err := parseAlertRule(tt.spec)
+		if tt.err {
+			if err == nil {
+				t.Errorf("parseAlertRule(%q) = %+v, want error", tt.spec, r)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseAlertRule(%q): %v", tt.spec, err)
+			continue
+		}
+		if r.kind != tt.kind || r.limit != tt.limit {
+			t.Errorf("parseAlertRule(%q) = %s %g, want %s %g", tt.spec, r.kind, r.limit, tt.kind, tt.limit)
+		}
+	}
+}
+
+// alertServer records the alerts POSTed to it and answers with status.
+type alertServer struct {
+	*httptest.Server
+	mu     sync.Mutex
+	alerts []alert
+}
+
+func newAlertServer(t *testing.T, status int) *alertServer {
+	s := &alertServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var a alert
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("got %s with Content-Type %q", r.Method, r.Header.Get("Content-Type"))
+		}
+		if err := json.NewDecoder(r.Body).Decode(&a); err != nil {
+			t.Errorf("decoding alert: %v", err)
+		}
+		s.mu.Lock()
+		s.alerts = append(s.alerts, a)
+		s.mu.Unlock()
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *alertServer) received() []alert {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]alert(nil), s.alerts...)
+}
+
+func TestPostAlert(t *testing.T) {
+	now := time.Date(2024, 1, 22, 3, 56, 0, 0, time.UTC)
+	a := alert{Time: now, EventTime: now, Rule: "count>10", Item: "10.0.0.1", Count: 11, Rank: 1, Message: "10.0.0.1 has 11 in window (> 10)"}
+
+	ok := newAlertServer(t, http.StatusNoContent)
+	if err := postAlert(ok.Client(), ok.URL, a); err != nil {
+		t.Fatalf("postAlert: %v", err)
+	}
+	got := ok.received()
+	if len(got) != 1 {
+		t.Fatalf("server got %d alerts, want 1", len(got))
+	}
+	if !got[0].Time.Equal(a.Time) || got[0].Rule != a.Rule || got[0].Item != a.Item || got[0].Count != a.Count || got[0].Message != a.Message {
+		t.Errorf("server got %+v, want %+v", got[0], a)
+	}
+
+	failing := newAlertServer(t, http.StatusInternalServerError)
+	if err := postAlert(failing.Client(), failing.URL, a); err == nil {
+		t.Error("postAlert to a 500 server succeeded")
+	}
+}
+
+func TestAlerterWebhook(t *testing.T) {
+	srv := newAlertServer(t, http.StatusOK)
+	var errs []error
+	a, err := newAlerter([]string{"count>10"}, []string{"webhook=" + srv.URL}, time.Minute, nil, func(err error) { errs = append(errs, err) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2024, 1, 22, 3, 56, 0, 0, time.UTC)
+	a.evaluate(now, now, []heap.Item{{Item: "a", Count: 20}, {Item: "b", Count: 15}, {Item: "c", Count: 5}}, nil)
+	a.close()
+
+	var items []string
+	for _, al := range srv.received() {
+		items = append(items, al.Item)
+	}
+	sort.Strings(items)
+	if len(items) != 2 || items[0] != "a" || items[1] != "b" {
+		t.Errorf("webhook got alerts for %v, want [a b]", items)
+	}
+	if len(errs) > 0 {
+		t.Errorf("sink errors: %v", errs)
+	}
+}
+
+func TestAlerterSlowSink(t *testing.T) {
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	t.Cleanup(slow.Close)
+	t.Cleanup(func() { close(release) })
+	fast := newAlertServer(t, http.StatusOK)
+
+	a, err := newAlerter([]string{"top-changed"}, []string{"webhook=" + slow.URL, "webhook=" + fast.URL}, 0, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2024, 1, 22, 3, 56, 0, 0, time.UTC)
+	a.evaluate(now, now, []heap.Item{{Item: "a", Count: 2}}, nil)
+	a.evaluate(now.Add(time.Second), now, []heap.Item{{Item: "b", Count: 3}}, nil)
+
+	deadline := time.Now().Add(webhookTimeout / 2)
+	for len(fast.received()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("a slow webhook held up the other sink")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// firedAt returns the rule and item of the conditions that fired at now.
+func firedAt(a *alerter, now time.Time) []string {
+	var fired []string
+	for _, st := range a.state {
+		if st.fired.Equal(now) {
+			fired = append(fired, st.last.Rule+" "+st.last.Item)
+		}
+	}
+	sort.Strings(fired)
+	return fired
+}
+
+func TestAlerterEvaluate(t *testing.T) {
+	type step struct {
+		after time.Duration // since the first step
+		items []heap.Item
+		fired []string
+	}
+	tests := []struct {
+		name  string
+		rules []string
+		steps []step
+	}{
+		{
+			name:  "fires once while the condition holds",
+			rules: []string{"count>10"},
+			steps: []step{
+				{0, []heap.Item{{Item: "a", Count: 11}, {Item: "b", Count: 3}}, []string{"count>10 a"}},
+				{time.Second, []heap.Item{{Item: "a", Count: 12}}, nil},
+				{2 * time.Minute, []heap.Item{{Item: "a", Count: 13}}, nil},
+			},
+		},
+		{
+			name:  "cooldown suppresses a condition that holds again",
+			rules: []string{"count>10"},
+			steps: []step{
+				{0, []heap.Item{{Item: "a", Count: 11}}, []string{"count>10 a"}},
+				{time.Second, []heap.Item{{Item: "a", Count: 9}}, nil},
+				{2 * time.Second, []heap.Item{{Item: "a", Count: 11}}, nil},
+				{2 * time.Minute, []heap.Item{{Item: "a", Count: 9}}, nil},
+				{3 * time.Minute, []heap.Item{{Item: "a", Count: 11}}, []string{"count>10 a"}},
+			},
+		},
+		{
+			name:  "rules and items are tracked separately",
+			rules: []string{"count>10", "count>20"},
+			steps: []step{
+				{0, []heap.Item{{Item: "a", Count: 15}}, []string{"count>10 a"}},
+				{time.Second, []heap.Item{{Item: "a", Count: 25}, {Item: "b", Count: 12}}, []string{"count>10 b", "count>20 a"}},
+			},
+		},
+		{
+			name:  "enter-top needs a previous leaderboard",
+			rules: []string{"enter-top=2"},
+			steps: []step{
+				{0, []heap.Item{{Item: "a", Count: 3}, {Item: "b", Count: 2}, {Item: "c", Count: 1}}, nil},
+				{time.Second, []heap.Item{{Item: "a", Count: 3}, {Item: "c", Count: 2}, {Item: "b", Count: 1}}, []string{"enter-top=2 c"}},
+			},
+		},
+		{
+			name:  "top-changed",
+			rules: []string{"top-changed"},
+			steps: []step{
+				{0, []heap.Item{{Item: "a", Count: 3}}, nil},
+				{time.Second, []heap.Item{{Item: "a", Count: 4}}, nil},
+				{2 * time.Second, []heap.Item{{Item: "b", Count: 5}, {Item: "a", Count: 4}}, []string{"top-changed b"}},
+			},
+		},
+	}
+	start := time.Date(2024, 1, 22, 3, 56, 0, 0, time.UTC)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := newAlerter(tt.rules, nil, time.Minute, nil, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer a.close()
+			for i, s := range tt.steps {
+				now := start.Add(s.after)
+				a.evaluate(now, now, s.items, nil)
+				if got := firedAt(a, now); !slices.Equal(got, s.fired) {
+					t.Errorf("step %d: fired %v, want %v", i, got, s.fired)
+				}
+			}
+		})
+	}
+}
+
+func TestAlerterKeepsStateOnReload(t *testing.T) {
+	start := time.Date(2024, 1, 22, 3, 56, 0, 0, time.UTC)
+	items := []heap.Item{{Item: "a", Count: 11}}
+	prev, err := newAlerter([]string{"count>10"}, nil, time.Minute, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prev.evaluate(start, start, items, nil)
+	prev.close()
+
+	a, err := newAlerter([]string{"count>10"}, nil, time.Minute, prev, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.close()
+	now := start.Add(time.Second)
+	a.evaluate(now, now, items, nil)
+	if got := firedAt(a, now); len(got) > 0 {
+		t.Errorf("reloaded alerter fired %v again", got)
+	}
+	if got := a.active(now); len(got) != 1 || got[0].Item != "a" {
+		t.Errorf("active = %+v, want the alert for a", got)
+	}
+}
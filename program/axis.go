@@ -0,0 +1,303 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	styles "github.com/charmbracelet/lipgloss"
+	plot "github.com/chriskim06/drawille-go"
+)
+
+// yAxisWidth is the width of the Y axis: a count label and the axis line.
+const yAxisWidth = 7
+
+// plotBounds is what the axes and legend need to know about the last Fill.
+type plotBounds struct {
+	lo, hi   float64 // smallest and largest plotted value
+	logScale bool    // values are math.Log of counts
+	legend   []legendEntry
+	dim      plot.Color // the rest of the top-K
+}
+
+// showAxes reports whether the plot pane has room for axes and a legend.
+func (m *model) showAxes() bool {
+	return m.plotW >= 4*yAxisWidth && m.plotH >= 6
+}
+
+// canvasSize returns the size of the drawille canvas inside the plot pane.
+func (m *model) canvasSize() (w, h int) {
+	if m.showAxes() {
+		// The legend sits above the canvas, the X axis below it.
+		return m.plotW - yAxisWidth, m.plotH - 2
+	}
+	return m.plotW, m.plotH
+}
+
+// setPlotBounds records the range and legend of the data just plotted.
+// Called with m.mu held.
+func (m *model) setPlotBounds(data [][]float64, logScale bool, legend []legendEntry, dim plot.Color) {
+	b := plotBounds{lo: math.Inf(1), logScale: logScale, legend: legend, dim: dim}
+	for _, series := range data {
+		for _, v := range series {
+			b.lo, b.hi = min(b.lo, v), max(b.hi, v)
+		}
+	}
+	if math.IsInf(b.lo, 1) {
+		b.lo = 0
+	}
+	m.plotBounds = b
+}
+
+// withAxes frames the rendered canvas with a legend above, a Y axis with
+// count labels on the left and an X axis with time ticks below. While the
+// mouse points at the canvas, a tooltip replaces the legend and the X axis
+// marks the column.
+func (m *model) withAxes(canvas string) string {
+	m.mu.Lock()
+	b := m.plotBounds
+	m.mu.Unlock()
+	w, h := m.canvasSize()
+	rows := strings.Split(canvas, "\n")
+
+	labels := make([]string, h)
+	for _, t := range yTicks(b, h) {
+		labels[t.row] = t.label
+	}
+	out := make([]string, 0, h+2)
+	top := legendLine(b, m.plotW)
+	if m.hover.active {
+		top = selectedFg.Render(cutLine(strings.Repeat(" ", yAxisWidth)+m.tooltip(w), m.plotW))
+	}
+	out = append(out, top)
+	for r := range h {
+		row := ""
+		if r < len(rows) {
+			row = rows[r]
+		}
+		axis := fmt.Sprintf("%*s │", yAxisWidth-2, "")
+		if labels[r] != "" {
+			axis = fmt.Sprintf("%*s ┤", yAxisWidth-2, labels[r])
+		}
+		out = append(out, borderFg.Render(axis)+row)
+	}
+	axis := []rune(m.xAxisLine(w))
+	if m.hover.active && m.hover.col < len(axis) {
+		axis[m.hover.col] = '▲'
+	}
+	out = append(out, borderFg.Render(strings.Repeat(" ", yAxisWidth-1)+"└"+string(axis)))
+	return strings.Join(out, "\n")
+}
+
+// legendLine names the items of the highlighted and pinned lines, as far as
+// they fit in w columns.
+func legendLine(b plotBounds, w int) string {
+	scale := "(count)"
+	if b.logScale {
+		scale = "(count, log scale)"
+	}
+	parts := []string{strings.Repeat(" ", yAxisWidth-2)}
+	used := yAxisWidth - 2
+	fits := func(s string) bool {
+		n := len([]rune(s)) + 2
+		if used+n > w {
+			return false
+		}
+		used += n
+		return true
+	}
+	for i, e := range b.legend {
+		s := e.String()
+		if i == 0 && !fits(s) {
+			// Always name the first line, cut to the width.
+			s = cutLine(s, max(1, w-used-2))
+			used = w
+		} else if i > 0 && !fits(s) {
+			break
+		}
+		parts = append(parts, colorStyle(e.color).Render(s))
+	}
+	for _, s := range []string{"── rest of top-K", scale} {
+		if !fits(s) {
+			break
+		}
+		style := borderFg
+		if s != scale {
+			style = colorStyle(b.dim)
+		}
+		parts = append(parts, style.Render(s))
+	}
+	return strings.Join(parts, "  ")
+}
+
+// colorStyle renders text in a drawille palette color.
+func colorStyle(c plot.Color) styles.Style {
+	if c < 0 {
+		return styles.NewStyle()
+	}
+	return styles.NewStyle().Foreground(styles.Color(fmt.Sprint(int(c))))
+}
+
+// yTick is a Y axis label on a canvas row.
+type yTick struct {
+	row   int
+	label string
+}
+
+// yTicks places count labels on the rows of a canvas h rows high, as the
+// canvas scales values: the lowest value on the bottom row, the highest on
+// the top row. Log scale labels are counts at powers of ten, then at 2 and 5
+// times those where there is room; linear labels are at a round step.
+func yTicks(b plotBounds, h int) []yTick {
+	if h < 2 || b.hi <= b.lo {
+		return []yTick{{row: h - 1, label: formatCount(b.valueCount(b.lo))}}
+	}
+	row := func(v float64) int {
+		// Same truncation as the canvas uses for plotted points.
+		return h - 1 - int((v-b.lo)/(b.hi-b.lo)*float64(h-1))
+	}
+	var ticks []yTick
+	taken := make([]bool, h)
+	place := func(count float64) {
+		v := count
+		if b.logScale {
+			v = math.Log(count)
+		}
+		if v < b.lo || v > b.hi {
+			return
+		}
+		r := row(v)
+		// Keep a free row between labels.
+		for _, near := range []int{r - 1, r, r + 1} {
+			if near >= 0 && near < h && taken[near] {
+				return
+			}
+		}
+		taken[r] = true
+		ticks = append(ticks, yTick{row: r, label: formatCount(count)})
+	}
+	if b.logScale {
+		top := math.Exp(b.hi)
+		for _, mult := range []float64{1, 5, 2} {
+			for p := 1.0; p <= top; p *= 10 {
+				place(mult * p)
+			}
+		}
+		return ticks
+	}
+	step := niceStep((b.hi - b.lo) / float64(max(1, (h-1)/3)))
+	for v := math.Ceil(b.lo/step) * step; v <= b.hi; v += step {
+		place(v)
+	}
+	return ticks
+}
+
+// valueCount turns a plotted value back into a count.
+func (b plotBounds) valueCount(v float64) float64 {
+	if b.logScale {
+		return math.Exp(v)
+	}
+	return v
+}
+
+// niceStep rounds step up to 1, 2 or 5 times a power of ten, at least 1.
+func niceStep(step float64) float64 {
+	if step <= 1 {
+		return 1
+	}
+	p := math.Pow(10, math.Floor(math.Log10(step)))
+	for _, mult := range []float64{1, 2, 5, 10} {
+		if mult*p >= step {
+			return mult * p
+		}
+	}
+	return 10 * p
+}
+
+// formatCount renders a count compactly: 950, 12.3k, 4.1M.
+func formatCount(v float64) string {
+	v = math.Round(v)
+	for _, unit := range []struct {
+		size   float64
+		suffix string
+	}{{1e9, "G"}, {1e6, "M"}, {1e3, "k"}} {
+		if v >= unit.size {
+			return strings.TrimSuffix(fmt.Sprintf("%.1f", v/unit.size), ".0") + unit.suffix
+		}
+	}
+	return fmt.Sprintf("%.0f", v)
+}
+
+// resample fills out with series stretched or squeezed to len(out) points,
+// each the maximum of the points it covers.
+func resample(series, out []float64) {
+	for x := range out {
+		from, to := x*len(series)/len(out), (x+1)*len(series)/len(out)
+		to = max(to, from+1)
+		out[x] = 0
+		for _, v := range series[from:min(to, len(series))] {
+			out[x] = max(out[x], v)
+		}
+	}
+}
+
+// fitToCanvas resamples data to one point per braille dot column, so the
+// window spans the canvas exactly. The canvas's own NumDataPoints scaling
+// rounds the step up and runs the newest points off the right edge.
+func (m *model) fitToCanvas(data [][]float64) [][]float64 {
+	w, _ := m.canvasSize()
+	dots := max(2, 2*w)
+	if len(m.plotFit) < len(data) {
+		m.plotFit = make([][]float64, len(data))
+	}
+	for i, series := range data {
+		if len(m.plotFit[i]) != dots {
+			m.plotFit[i] = make([]float64, dots)
+		}
+		resample(series, m.plotFit[i])
+	}
+	return m.plotFit[:len(data)]
+}
+
+// xTickSteps are the candidate spacings of X axis time ticks.
+var xTickSteps = []time.Duration{
+	time.Second, 2 * time.Second, 5 * time.Second, 10 * time.Second, 15 * time.Second, 30 * time.Second,
+	time.Minute, 2 * time.Minute, 5 * time.Minute, 10 * time.Minute, 15 * time.Minute, 30 * time.Minute,
+	time.Hour, 2 * time.Hour, 3 * time.Hour, 6 * time.Hour, 12 * time.Hour, 24 * time.Hour,
+}
+
+// xAxisLine renders the X axis under a chart w columns wide that spans the
+// window, with round times between the window's edges marked.
+func (m *model) xAxisLine(w int) string {
+	row := []rune(strings.Repeat("─", max(0, w)))
+	tick := m.viewTick()
+	if w < 1 || tick.IsZero() {
+		return string(row)
+	}
+	start := tick.Add(-config.WindowSize)
+	column := func(t time.Time) int {
+		return int(float64(t.Sub(start)) / float64(config.WindowSize) * float64(w))
+	}
+	for _, step := range xTickSteps {
+		layout := "15:04:05"
+		if step%time.Minute == 0 {
+			layout = "15:04"
+		}
+		// Leave room for the label and a gap before the next tick.
+		if column(start.Add(step))-column(start) < len(layout)+3 {
+			continue
+		}
+		for t := start.Truncate(step).Add(step); t.Before(tick); t = t.Add(step) {
+			x := column(t)
+			label := []rune(t.UTC().Format(layout))
+			if x < 0 || x+len(label) >= w {
+				continue
+			}
+			row[x] = '┴'
+			copy(row[x+1:], label)
+		}
+		break
+	}
+	return string(row)
+}
@@ -0,0 +1,346 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+type benchOptions struct {
+	sweeps         stringList
+	format         string
+	refreshEvery   int
+	recordsPerTick int
+	renderFPS      int
+	parse          bool
+}
+
+func registerBenchFlags(fs *flag.FlagSet, o *benchOptions) {
+	fs.Var(&o.sweeps, "sweep", "Sweep a flag over comma-separated values, repeatable (e.g. width=1000,3000 or tick=1s,1m)")
+	fs.StringVar(&o.format, "format", "table", "Output format: table or csv")
+	fs.IntVar(&o.refreshEvery, "refresh-every", 10000, "Measure a full Top-K refresh every this many records")
+	fs.IntVar(&o.recordsPerTick, "records-per-tick", 1000, "Advance the window every this many records when the input has no timestamps")
+	fs.IntVar(&o.renderFPS, "render-fps", 0, "Simulate plot rendering at this rate during the timed pass, contending for the sketch lock (0 = off)")
+	fs.BoolVar(&o.parse, "parse", false, "Include parsing in the timed pass by running the input readers over the raw input")
+}
+
+type benchRecord struct {
+	item  string
+	count uint32
+	time  time.Time
+}
+
+type benchResult struct {
+	values      []string
+	recordsPerS float64
+	allocsPerR  float64
+	sizeBytes   int
+	accuracy    accuracy
+	refresh     time.Duration
+}
+
+// runBench replays the input through every combination of -sweep values with
+// no TUI, comparing each sketch against exact window counts.
+//
+//	logspeed bench -in ./data/access.log -access-log -k 20 -tick 1m -window 1h \
+//		-sweep width=1000,3000,10000 -sweep depth=3,4 -sweep decay=0.9,0.95
+func runBench(args []string) error {
+	var opts benchOptions
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	registerBenchFlags(fs, &opts)
+	base, err := loadConfig(fs, args)
+	if err != nil {
+		return err
+	}
+	if opts.format != "table" && opts.format != "csv" {
+		return fmt.Errorf("-format must be table or csv")
+	}
+	if opts.refreshEvery < 1 {
+		return fmt.Errorf("-refresh-every must be >= 1")
+	}
+	config = base
+
+	names, grid, err := expandSweeps(opts.sweeps)
+	if err != nil {
+		return err
+	}
+	raw, err := readBenchInput(&base)
+	if err != nil {
+		return err
+	}
+	records, err := loadBenchRecords(&base, raw)
+	if err != nil {
+		return err
+	}
+	if len(records) == 0 {
+		return fmt.Errorf("no records in input")
+	}
+	fmt.Fprintf(os.Stderr, "bench: %d records, %d configurations\n", len(records), len(grid))
+
+	var results []benchResult
+	for _, point := range grid {
+		pointArgs := append([]string(nil), args...)
+		for i, name := range names {
+			pointArgs = append(pointArgs, "-"+name+"="+point[i])
+		}
+		var ignored benchOptions
+		pfs := flag.NewFlagSet("bench", flag.ContinueOnError)
+		registerBenchFlags(pfs, &ignored)
+		c, err := loadConfig(pfs, pointArgs)
+		if err != nil {
+			return fmt.Errorf("%s: %w", strings.Join(point, ","), err)
+		}
+		r, err := benchConfig(&c, raw, records, opts)
+		if err != nil {
+			return fmt.Errorf("%s: %w", strings.Join(point, ","), err)
+		}
+		r.values = point
+		results = append(results, r)
+	}
+	return writeBenchResults(os.Stdout, opts.format, names, results)
+}
+
+// expandSweeps turns name=v1,v2 specs into the cartesian product of values.
+func expandSweeps(sweeps []string) ([]string, [][]string, error) {
+	var names []string
+	grid := [][]string{nil}
+	for _, spec := range sweeps {
+		name, list, ok := strings.Cut(spec, "=")
+		name = strings.TrimPrefix(strings.TrimSpace(name), "-")
+		if !ok || name == "" || list == "" {
+			return nil, nil, fmt.Errorf("-sweep %q: expected name=v1,v2,...", spec)
+		}
+		names = append(names, name)
+		var next [][]string
+		for _, point := range grid {
+			for _, v := range strings.Split(list, ",") {
+				next = append(next, append(append([]string(nil), point...), strings.TrimSpace(v)))
+			}
+		}
+		grid = next
+	}
+	return names, grid, nil
+}
+
+// benchConfig runs two passes over the records: a timed pass with only the
+// sketch, and a verification pass alongside an exact window. With -parse the
+// timed pass reads raw through the same readers as the TUI instead.
+func benchConfig(c *Config, raw []byte, records []benchRecord, opts benchOptions) (benchResult, error) {
+	norm, _ := newNormalizer(c.Normalize)
+	ticks := int(c.WindowSize / c.TickSize)
+
+	// Timed pass: ingest through a headless model, with a simulated renderer
+	// contending for the sketch lock like the TUI's plot refresh.
+	config = *c
+	m := newModel(newSketch(c))
+	m.normalizer.Store(norm)
+	stopRenderer := startBenchRenderer(m, opts.renderFPS)
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+	if opts.parse {
+		config.Replay, config.Pace = false, 0
+		if err := m.readInput(bytes.NewReader(raw)); err != nil {
+			stopRenderer()
+			return benchResult{}, err
+		}
+	} else {
+		replayBench(c, records, opts, func(item string, count uint32) {
+			m.ingest(m.normalizer.Load().apply(item), count)
+		}, func(n int) {
+			m.flushIngest()
+			m.sketchMu.Lock()
+			m.sketch.Ticks(n)
+			m.sketchMu.Unlock()
+		}, nil)
+	}
+	m.flushIngest()
+	elapsed := time.Since(start)
+	runtime.ReadMemStats(&after)
+	stopRenderer()
+	sketch := m.sketch
+	result := benchResult{
+		recordsPerS: float64(len(records)) / max(elapsed.Seconds(), 1e-9),
+		allocsPerR:  float64(after.Mallocs-before.Mallocs) / float64(len(records)),
+		sizeBytes:   sketch.SizeBytes(),
+	}
+
+	sketch = newSketch(c)
+	v := newVerifier(ticks)
+	var refreshTotal time.Duration
+	refreshes := 0
+	replayBench(c, records, opts, func(item string, count uint32) {
+		item = norm.apply(item)
+		sketch.Add(item, count)
+		v.exact.add(item, count)
+	}, func(n int) {
+		sketch.Ticks(n)
+		v.exact.tick(n)
+	}, func() {
+		t := time.Now()
+		items := sketch.SortedSlice()
+		refreshTotal += time.Since(t)
+		refreshes++
+		v.observe(v.exact.measure(items, c.K))
+	})
+	result.accuracy = v.mean()
+	if refreshes > 0 {
+		result.refresh = refreshTotal / time.Duration(refreshes)
+	}
+	return result, nil
+}
+
+// startBenchRenderer reads the sketch the way updatePlot does, fps times per
+// second, until the returned function is called.
+func startBenchRenderer(m *model, fps int) (stop func()) {
+	if fps <= 0 {
+		return func() {}
+	}
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(time.Second / time.Duration(fps))
+		defer ticker.Stop()
+		series := make([]float64, m.sketch.BucketHistoryLength)
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				m.flushIngest()
+				m.sketchMu.Lock()
+				for _, item := range m.sketch.SortedSlice() {
+					fillSeriesFromSketch(m.sketch, item, series, false)
+				}
+				m.sketchMu.Unlock()
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		wg.Wait()
+	}
+}
+
+// replayBench feeds records to add, advancing time with ticks the same way
+// the TUI does, and calls refresh every opts.refreshEvery records.
+func replayBench(c *Config, records []benchRecord, opts benchOptions, add func(string, uint32), ticks func(int), refresh func()) {
+	var last time.Time
+	for i, r := range records {
+		switch {
+		case !r.time.IsZero():
+			t := r.time.Truncate(c.TickSize)
+			if last.IsZero() {
+				last = t
+			} else if n := int(t.Sub(last) / c.TickSize); n > 0 {
+				ticks(n)
+				last = t
+			}
+		case opts.recordsPerTick > 0 && i > 0 && i%opts.recordsPerTick == 0:
+			ticks(1)
+		}
+		add(r.item, r.count)
+		if refresh != nil && (i+1)%opts.refreshEvery == 0 {
+			refresh()
+		}
+	}
+	if refresh != nil && len(records)%opts.refreshEvery != 0 {
+		refresh()
+	}
+}
+
+// readBenchInput reads the whole input into memory so that every
+// configuration replays identical data.
+func readBenchInput(c *Config) ([]byte, error) {
+	if c.InputPath != "" {
+		return os.ReadFile(c.InputPath)
+	}
+	return io.ReadAll(os.Stdin)
+}
+
+// loadBenchRecords parses raw up front so that parsing is not measured
+// unless -parse is given.
+func loadBenchRecords(c *Config, raw []byte) ([]benchRecord, error) {
+	r := bytes.NewReader(raw)
+	var records []benchRecord
+	full := func() bool { return c.MaxLines > 0 && len(records) >= c.MaxLines }
+	if c.JSON {
+		dec := json.NewDecoder(bufio.NewReader(r))
+		for !full() {
+			var rec jsonRecord
+			if err := dec.Decode(&rec); err != nil {
+				if err == io.EOF {
+					break
+				}
+				return nil, err
+			}
+			records = append(records, benchRecord{item: rec.Item, count: uint32(max(1, rec.Count)), time: rec.eventTime(c.TimestampLayout)})
+		}
+		return records, nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() && !full() {
+		if !c.AccessLog {
+			records = append(records, benchRecord{item: scanner.Text(), count: 1})
+			continue
+		}
+		ip, ts, ok := parseAccessLogLine(scanner.Text())
+		if !ok {
+			continue
+		}
+		t, _ := time.Parse(c.TimestampLayout, ts)
+		records = append(records, benchRecord{item: ip, count: 1, time: t})
+	}
+	return records, scanner.Err()
+}
+
+func writeBenchResults(w io.Writer, format string, names []string, results []benchResult) error {
+	header := append(append([]string(nil), names...),
+		"rec/s", "allocs/rec", "size", "precision", "recall", "rank_corr", "err_mean", "err_max", "refresh")
+	rows := [][]string{header}
+	for _, r := range results {
+		row := append(append([]string(nil), r.values...),
+			strconv.FormatFloat(r.recordsPerS, 'f', 0, 64),
+			strconv.FormatFloat(r.allocsPerR, 'f', 2, 64),
+			strconv.Itoa(r.sizeBytes),
+			strconv.FormatFloat(r.accuracy.precision, 'f', 3, 64),
+			strconv.FormatFloat(r.accuracy.recall, 'f', 3, 64),
+			strconv.FormatFloat(r.accuracy.rankCorr, 'f', 3, 64),
+			strconv.FormatFloat(r.accuracy.meanRelErr, 'f', 4, 64),
+			strconv.FormatFloat(r.accuracy.maxRelErr, 'f', 4, 64),
+			r.refresh.String(),
+		)
+		if format == "table" {
+			row[len(names)+2] = formatBytes(int64(r.sizeBytes))
+		}
+		rows = append(rows, row)
+	}
+
+	if format == "csv" {
+		cw := csv.NewWriter(w)
+		if err := cw.WriteAll(rows); err != nil {
+			return err
+		}
+		return cw.Error()
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t")+"\t")
+	}
+	return tw.Flush()
+}
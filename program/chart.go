@@ -0,0 +1,351 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	styles "github.com/charmbracelet/lipgloss"
+	plot "github.com/chriskim06/drawille-go"
+	"github.com/keilerkonzept/topk/heap"
+)
+
+// Chart modes, cycled with c.
+const (
+	chartLines   = "lines"   // overlaid braille lines of all items
+	chartStacked = "stacked" // stacked area of the top items
+	chartHeatmap = "heatmap" // items × buckets, count as cell color
+	chartBars    = "bars"    // horizontal bars of window counts
+)
+
+var chartModes = []string{chartLines, chartStacked, chartHeatmap, chartBars}
+
+func (m *model) cycleChart() {
+	for i, mode := range chartModes {
+		if mode == m.chartMode {
+			m.chartMode = chartModes[(i+1)%len(chartModes)]
+			return
+		}
+	}
+	m.chartMode = chartLines
+}
+
+// stackPalette colors the bands of the stacked chart, from the top item
+// down; the rest of the top-K is stacked on top in the dim color.
+var stackPalette = []plot.Color{
+	plot.DodgerBlue,
+	plot.DarkOrange,
+	plot.SpringGreen,
+	plot.Fuchsia,
+	plot.Gold,
+	plot.MediumPurple,
+	plot.Cyan,
+	plot.HotPink,
+}
+
+// heatRamp is the heatmap's background color scale, from the lowest to the
+// highest count.
+var heatRamp = []string{"17", "19", "21", "27", "33", "39", "45", "50", "226", "220", "214", "208", "196"}
+
+// chartData is the per-bucket data of the leaderboard, in list order, as
+// the non-line charts draw it. Only used by the update loop.
+type chartData struct {
+	items     []heap.Item
+	series    [][]float64 // per-bucket counts, oldest first
+	selected  string
+	highlight plot.Color
+	dim       plot.Color
+}
+
+// updateChart refreshes the data of the non-line charts.
+func (m *model) updateChart(items []heap.Item, current heap.Item, snap *leaderboardSnapshot, highlight, dim plot.Color) {
+	m.sketchMu.Lock()
+	for i, item := range items {
+		m.itemSeries(snap, item, m.plotData[i], false)
+	}
+	m.sketchMu.Unlock()
+	m.chart = chartData{
+		items:     items,
+		series:    m.plotData[:len(items)],
+		selected:  current.Item,
+		highlight: highlight,
+		dim:       dim,
+	}
+}
+
+// chartView renders the current non-line chart as h lines of width w.
+func (m *model) chartView(w, h int) string {
+	if len(m.chart.items) == 0 {
+		return padLines([]string{"no items yet"}, w, h)
+	}
+	switch m.chartMode {
+	case chartStacked:
+		return m.stackedView()
+	case chartHeatmap:
+		return m.heatmapView(w, h)
+	}
+	return m.barsView(w, h)
+}
+
+// itemColor returns the color an item is drawn in outside the stacked
+// chart: its pin color, the highlight if selected, or none.
+func (m *model) itemColor(item string) (plot.Color, bool) {
+	if pn, ok := m.pins.find(item); ok {
+		return pn.color, true
+	}
+	if item == m.chart.selected {
+		return m.chart.highlight, true
+	}
+	return 0, false
+}
+
+// stackedView stacks the per-bucket counts of the top items, one colored
+// band each, with the rest of the top-K on top, framed by the plot axes.
+func (m *model) stackedView() string {
+	cw, ch := m.canvasSize()
+	d := &m.chart
+	n := min(len(d.items), len(stackPalette))
+	// bands[i][x] is the top of band i in column x.
+	bands := make([][]float64, n+1)
+	col := make([]float64, cw)
+	var peak float64
+	for i := range bands {
+		bands[i] = make([]float64, cw)
+		from, to := i, i+1
+		if i == n {
+			from, to = n, len(d.items)
+		}
+		for _, series := range d.series[from:to] {
+			resample(series, col)
+			for x, v := range col {
+				bands[i][x] += v
+			}
+		}
+		if i > 0 {
+			for x := range bands[i] {
+				bands[i][x] += bands[i-1][x]
+			}
+		}
+	}
+	for _, v := range bands[n] {
+		peak = max(peak, v)
+	}
+
+	bandStyles := make([]styles.Style, n+1)
+	legend := make([]legendEntry, n)
+	for i := range n {
+		bandStyles[i] = colorStyle(stackPalette[i])
+		legend[i] = legendEntry{Item: d.items[i], color: stackPalette[i]}
+	}
+	bandStyles[n] = colorStyle(d.dim)
+
+	rows := make([]string, ch)
+	for r := range rows {
+		// Same scale as the axis: row b from the bottom is the value b*step.
+		v := float64(ch-1-r) * peak / float64(max(1, ch-1))
+		var sb strings.Builder
+		run, runBand := 0, -1
+		flush := func() {
+			if run == 0 {
+				return
+			}
+			cells := strings.Repeat("█", run)
+			if runBand < 0 {
+				cells = strings.Repeat(" ", run)
+			} else {
+				cells = bandStyles[runBand].Render(cells)
+			}
+			sb.WriteString(cells)
+			run = 0
+		}
+		for x := range cw {
+			band := -1
+			if v < bands[n][x] {
+				band = 0
+				for bands[band][x] <= v {
+					band++
+				}
+			}
+			if band != runBand {
+				flush()
+				runBand = band
+			}
+			run++
+		}
+		flush()
+		rows[r] = sb.String()
+	}
+
+	m.mu.Lock()
+	m.plotBounds = plotBounds{hi: peak, legend: legend, dim: d.dim}
+	m.mu.Unlock()
+	canvas := strings.Join(rows, "\n")
+	if !m.showAxes() {
+		return canvas
+	}
+	return m.withAxes(canvas)
+}
+
+// chartLabelWidth returns the width of the item labels of the heatmap and
+// bar chart.
+func chartLabelWidth(items []heap.Item, w int) int {
+	lw := 0
+	for _, item := range items {
+		lw = max(lw, len([]rune(item.Item)))
+	}
+	return min(lw, 20, w/3) + 1
+}
+
+// visibleRows returns the range of items shown in rows lines, scrolled so
+// the selected item is visible.
+func (d *chartData) visibleRows(rows int) (from, to int) {
+	sel := 0
+	for i, item := range d.items {
+		if item.Item == d.selected {
+			sel = i
+		}
+	}
+	from = max(0, sel-rows+1)
+	return from, min(len(d.items), from+rows)
+}
+
+// label renders an item's name cut or padded to lw-1 columns and a space,
+// in the item's color.
+func (m *model) label(item string, lw int) string {
+	s := cutLine(item, lw-1)
+	s += strings.Repeat(" ", lw-len([]rune(s)))
+	if c, ok := m.itemColor(item); ok {
+		return colorStyle(c).Render(s)
+	}
+	return s
+}
+
+// heatmapView renders one row per item and one column per resampled bucket,
+// colored by count.
+func (m *model) heatmapView(w, h int) string {
+	d := &m.chart
+	logScale := m.logScale.Load()
+	lw := chartLabelWidth(d.items, w)
+	cw := max(1, w-lw)
+	cells := make([][]float64, len(d.items))
+	var peak float64
+	for i, series := range d.series {
+		cells[i] = make([]float64, cw)
+		resample(series, cells[i])
+		for _, v := range cells[i] {
+			peak = max(peak, v)
+		}
+	}
+	level := func(v float64) int {
+		if v <= 0 || peak <= 0 {
+			return -1
+		}
+		f := v / peak
+		if logScale {
+			f = math.Log1p(v) / math.Log1p(peak)
+		}
+		return min(len(heatRamp)-1, int(math.Ceil(f*float64(len(heatRamp)-1))))
+	}
+
+	scale := "count per bucket"
+	if logScale {
+		scale += ", log scale"
+	}
+	var ramp strings.Builder
+	for l := range heatRamp {
+		ramp.WriteString(heatCells(l, 1))
+	}
+	lines := []string{fmt.Sprintf("%s 1 %s %s (%s)", strings.Repeat(" ", lw-1), ramp.String(), formatCount(peak), scale)}
+	from, to := d.visibleRows(h - 2)
+	for i := from; i < to; i++ {
+		var row strings.Builder
+		row.WriteString(m.label(d.items[i].Item, lw))
+		for x := 0; x < cw; {
+			// Render runs of one color together.
+			l, run := level(cells[i][x]), 1
+			for x+run < cw && level(cells[i][x+run]) == l {
+				run++
+			}
+			row.WriteString(heatCells(l, run))
+			x += run
+		}
+		lines = append(lines, row.String())
+	}
+	for len(lines) < h-1 {
+		lines = append(lines, "")
+	}
+	lines = append(lines, strings.Repeat(" ", lw)+borderFg.Render(m.xAxisLine(cw)))
+	return joinLines(lines, w, h)
+}
+
+// heatCells renders n cells of heat level l; level -1 is blank.
+func heatCells(l, n int) string {
+	cells := strings.Repeat(" ", n)
+	if l < 0 {
+		return cells
+	}
+	return styles.NewStyle().Background(styles.Color(heatRamp[l])).Render(cells)
+}
+
+// barEighths draws the fractional end of a bar.
+var barEighths = []rune(" ▏▎▍▌▋▊▉")
+
+// barsView renders one horizontal bar per item, the sum of its per-bucket
+// counts over the window.
+func (m *model) barsView(w, h int) string {
+	d := &m.chart
+	logScale := m.logScale.Load()
+	lw := chartLabelWidth(d.items, w)
+	sums := make([]float64, len(d.items))
+	var peak float64
+	for i, series := range d.series {
+		for _, v := range series {
+			sums[i] += v
+		}
+		peak = max(peak, sums[i])
+	}
+	countW := len(formatCount(peak)) + 1
+	bw := max(1, w-lw-countW)
+
+	scale := "count in window"
+	if logScale {
+		scale += ", log scale"
+	}
+	lines := []string{strings.Repeat(" ", lw) + borderFg.Render(scale)}
+	from, to := d.visibleRows(h - 1)
+	for i := from; i < to; i++ {
+		f := 0.0
+		if peak > 0 {
+			f = sums[i] / peak
+			if logScale {
+				f = math.Log1p(sums[i]) / math.Log1p(peak)
+			}
+		}
+		eighths := int(math.Round(f * float64(bw*8)))
+		bar := strings.Repeat("█", eighths/8)
+		if eighths%8 > 0 {
+			bar += string(barEighths[eighths%8])
+		}
+		if c, ok := m.itemColor(d.items[i].Item); ok {
+			bar = colorStyle(c).Render(bar)
+		}
+		pad := strings.Repeat(" ", bw-(eighths+7)/8)
+		lines = append(lines, m.label(d.items[i].Item, lw)+bar+pad+" "+formatCount(sums[i]))
+	}
+	return joinLines(lines, w, h)
+}
+
+// joinLines pads styled lines to width w and cuts or pads them to h lines.
+// Lines must not be wider than w.
+func joinLines(lines []string, w, h int) string {
+	lines = lines[:min(len(lines), h)]
+	for len(lines) < h {
+		lines = append(lines, "")
+	}
+	for i, line := range lines {
+		if pad := w - styles.Width(line); pad > 0 {
+			lines[i] = line + strings.Repeat(" ", pad)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
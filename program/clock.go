@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/keilerkonzept/topk/sliding"
+)
+
+// Kinds of event-time discontinuity.
+const (
+	clockGap       = "gap"       // time jumped forward by more than the window
+	clockBackwards = "backwards" // time jumped back by more than the window
+)
+
+type clockMarker struct {
+	kind  string
+	tick  time.Time // tick at which the jump happened
+	delta time.Duration
+}
+
+// clockEvents records event-time jumps for the plot markers and STATS.
+// Guarded by the model's mu.
+type clockEvents struct {
+	gaps, backwards int
+	last            map[string]clockMarker
+	markers         []clockMarker
+}
+
+func (m *model) recordClockEvent(kind string, tick time.Time, delta time.Duration) {
+	marker := clockMarker{kind: kind, tick: tick, delta: delta}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c := &m.clock
+	switch kind {
+	case clockGap:
+		c.gaps++
+	case clockBackwards:
+		c.backwards++
+	}
+	if c.last == nil {
+		c.last = make(map[string]clockMarker)
+	}
+	c.last[kind] = marker
+	c.markers = append(c.markers, marker)
+	// Only markers inside the window can be drawn.
+	for len(c.markers) > 0 && !c.markers[0].tick.After(tick.Add(-config.WindowSize)) {
+		c.markers = c.markers[1:]
+	}
+}
+
+// clockStats is the STATS line for event-time jumps, or "" if there were none.
+func (m *model) clockStats() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c := m.clock
+	if c.gaps == 0 && c.backwards == 0 {
+		return ""
+	}
+	var parts []string
+	if c.gaps > 0 {
+		g := c.last[clockGap]
+		parts = append(parts, fmt.Sprintf("%d gaps (last +%s at %s)", c.gaps, g.delta, g.tick.UTC().Format(time.RFC3339)))
+	}
+	if c.backwards > 0 {
+		b := c.last[clockBackwards]
+		parts = append(parts, fmt.Sprintf("%d backwards (last -%s at %s)", c.backwards, b.delta, b.tick.UTC().Format(time.RFC3339)))
+	}
+	return "clock: " + strings.Join(parts, ", ")
+}
+
+func (m *model) hasClockEvents() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.clock.gaps > 0 || m.clock.backwards > 0
+}
+
+// markerLine renders the jumps inside the window as a row of width w under
+// the plot, aligned with the plot's time axis: ▲ for a gap, ▼ for time going
+// backwards.
+func (m *model) markerLine(w int) string {
+	latest := m.viewTick()
+	m.mu.Lock()
+	markers := append([]clockMarker(nil), m.clock.markers...)
+	m.mu.Unlock()
+	if w < 1 || latest.IsZero() {
+		return ""
+	}
+	row := []rune(strings.Repeat(" ", w))
+	visible := false
+	for _, mk := range markers {
+		age := latest.Sub(mk.tick)
+		if age < 0 || age >= config.WindowSize {
+			continue
+		}
+		x := int(float64(w-1) * (1 - float64(age)/float64(config.WindowSize)))
+		row[x] = '▲'
+		if mk.kind == clockBackwards {
+			row[x] = '▼'
+		}
+		visible = true
+	}
+	if !visible {
+		return ""
+	}
+	return string(row)
+}
+
+// emptySketch empties the window, which is much cheaper than ticking the
+// sketch by more than a window. An empty sketch with the same settings is
+// allocated before taking sketchMu, so ingest and rendering only wait for the
+// swap rather than for every bucket's history to be cleared.
+//
+// The price is a full width·depth·history allocation per gap, with the old
+// sketch left to the collector, so memory briefly doubles. Gaps that long
+// are rare, and clearing buckets lazily would need a generation counter
+// inside the sliding package's buckets.
+func (m *model) emptySketch() {
+	m.sketchMu.Lock()
+	s := m.sketch
+	m.sketchMu.Unlock()
+	empty := sliding.New(s.K, s.WindowSize,
+		sliding.WithWidth(s.Width),
+		sliding.WithDepth(s.Depth),
+		sliding.WithDecay(s.Decay),
+		sliding.WithDecayLUTSize(len(s.DecayLUT)),
+		sliding.WithBucketHistoryLength(s.BucketHistoryLength),
+	)
+	m.sketchMu.Lock()
+	// A config reload may have swapped in an empty sketch meanwhile.
+	if m.sketch == s {
+		m.sketch = empty
+	}
+	m.total.reset()
+	if m.verifier != nil {
+		m.verifier.exact.reset()
+	}
+	m.sketchMu.Unlock()
+}
+
+// restartSession treats a backward jump as the start of a new input: the
+// window is emptied and event time restarts from the record's time.
+func (m *model) restartSession(clock *eventClock, t time.Time) {
+	m.flushIngest()
+	m.emptySketch()
+	clock.mu.Lock()
+	clock.last, clock.prevEvent, clock.arrival = time.Time{}, time.Time{}, time.Time{}
+	clock.mu.Unlock()
+	m.mu.Lock()
+	m.startTick = time.Time{}
+	m.mu.Unlock()
+	clock.maxSeen = t
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGapEmptiesWindow(t *testing.T) {
+	tests := []struct {
+		name       string
+		newSession bool
+		next       time.Duration // event time of the next record, after the first
+	}{
+		{"gap", false, 2 * time.Hour},
+		{"backwards with -new-session-on-backwards", true, -2 * time.Hour},
+	}
+	start := time.Date(2024, 1, 22, 3, 0, 0, 0, time.UTC)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setConfig(t, func(c *Config) {
+				c.K = 10
+				c.Width, c.Depth = 64, 3
+				c.TickSize = time.Minute
+				c.WindowSize = time.Hour
+				c.HistoryLength = 12
+				c.Verify = true
+				c.NewSession = tt.newSession
+				c.AllowedLateness = 0
+				c.Replay = false
+			})
+			m := newModel(newSketch(&config))
+			if m.verifier == nil {
+				t.Fatal("no verifier")
+			}
+			clock := m.newEventClock()
+			for i, item := range testItems(1000) {
+				m.countEvent(clock, start.Add(time.Duration(i)*time.Second), item, 1)
+			}
+			m.flushIngest()
+			m.sketchMu.Lock()
+			before := m.sketch
+			m.sketchMu.Unlock()
+
+			m.countEvent(clock, start.Add(tt.next), "new", 1)
+			m.flushIngest()
+
+			m.sketchMu.Lock()
+			defer m.sketchMu.Unlock()
+			s := m.sketch
+			if s == before {
+				t.Fatal("the sketch was not replaced")
+			}
+			if s.K != before.K || s.Width != before.Width || s.Depth != before.Depth || s.WindowSize != before.WindowSize ||
+				s.BucketHistoryLength != before.BucketHistoryLength || s.Decay != before.Decay || len(s.DecayLUT) != len(before.DecayLUT) {
+				t.Errorf("the new sketch has different settings: %+v", s)
+			}
+			items := s.SortedSlice()
+			if len(items) != 1 || items[0].Item != "new" || items[0].Count != 1 {
+				t.Errorf("window holds %v, want only the new record", items)
+			}
+			if m.total.sum != 1 {
+				t.Errorf("window total = %d, want 1", m.total.sum)
+			}
+			m.verifier.exact.mu.Lock()
+			exact := m.verifier.exact.topKLocked(10)
+			m.verifier.exact.mu.Unlock()
+			if len(exact) != 1 || exact[0].Item != "new" {
+				t.Errorf("exact counts hold %v, want only the new record", exact)
+			}
+		})
+	}
+}
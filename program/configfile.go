@@ -0,0 +1,424 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// configEntry is a single setting read from a config file. Keys are flag
+// names without the leading dash; profile is empty for top-level settings.
+type configEntry struct {
+	profile string
+	key     string
+	value   string
+	line    int
+}
+
+// configFile is a parsed -config file.
+type configFile struct {
+	path     string
+	entries  []configEntry
+	profiles map[string]bool
+}
+
+// flagError is a validation error attributed to a single flag, so that it can
+// be reported at the config file line that set it.
+type flagError struct {
+	flag string
+	msg  string
+}
+
+func (e *flagError) Error() string { return "-" + e.flag + " " + e.msg }
+
+func flagErrorf(flag string, format string, args ...any) error {
+	return &flagError{flag: flag, msg: fmt.Sprintf(format, args...)}
+}
+
+// loadConfigFile parses a config file. The format is chosen by extension:
+// .json, .toml, or .yaml/.yml. Only the subset needed for flat settings is
+// supported: scalar values, lists of scalars, and a "profiles" table holding
+// one table of settings per profile name.
+//
+//	# logspeed.yaml
+//	k: 20
+//	window: 1h
+//	profiles:
+//	  fast:
+//	    plot-fps: 5
+//	    normalize: [ipv4-prefix=24, ipv6-prefix=64]
+func loadConfigFile(path string) (*configFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cf := &configFile{path: path, profiles: make(map[string]bool)}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		err = cf.parseJSON(data)
+	case ".toml":
+		err = cf.parseTOML(data)
+	case ".yaml", ".yml":
+		err = cf.parseYAML(data)
+	default:
+		return nil, fmt.Errorf("%s: unknown config format (use .yaml, .yml, .toml or .json)", path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return cf, nil
+}
+
+// applyConfigFile applies the -config file and -profile to fs. Flags already
+// set on the command line take precedence over file values.
+func applyConfigFile(fs *flag.FlagSet, path, profile string) (map[string]string, error) {
+	if path == "" {
+		if profile != "" {
+			return nil, flagErrorf("profile", "requires -config")
+		}
+		return nil, nil
+	}
+	cf, err := loadConfigFile(path)
+	if err != nil {
+		return nil, err
+	}
+	explicit := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+	return cf.apply(fs, profile, explicit)
+}
+
+// withConfigSource prefixes a validation error with the config file location
+// of the offending setting, if it came from a file.
+func withConfigSource(err error, sources map[string]string) error {
+	var fe *flagError
+	if errors.As(err, &fe) {
+		if src, ok := sources[fe.flag]; ok {
+			return fmt.Errorf("%s: %w", src, err)
+		}
+	}
+	return err
+}
+
+func (cf *configFile) errorf(line int, format string, args ...any) error {
+	return fmt.Errorf("%s:%d: %s", cf.path, line, fmt.Sprintf(format, args...))
+}
+
+// add records a setting found at path (the chain of keys leading to it).
+func (cf *configFile) add(line int, path []string, values ...string) error {
+	switch {
+	case len(path) == 1 && path[0] != "profiles":
+		for _, v := range values {
+			cf.entries = append(cf.entries, configEntry{key: path[0], value: v, line: line})
+		}
+	case len(path) == 3 && path[0] == "profiles":
+		cf.profiles[path[1]] = true
+		for _, v := range values {
+			cf.entries = append(cf.entries, configEntry{profile: path[1], key: path[2], value: v, line: line})
+		}
+	default:
+		return cf.errorf(line, "unexpected setting %q (use top-level keys or profiles.<name>.<key>)", strings.Join(path, "."))
+	}
+	return nil
+}
+
+// apply sets the file's top-level settings and then the given profile's
+// settings on fs. Flags in explicit (set on the command line) are left alone.
+// It returns the location of each applied setting, keyed by flag name.
+func (cf *configFile) apply(fs *flag.FlagSet, profile string, explicit map[string]bool) (map[string]string, error) {
+	if profile != "" && !cf.profiles[profile] {
+		return nil, fmt.Errorf("%s: no profile %q", cf.path, profile)
+	}
+	// A profile replaces top-level values of the same key, including lists.
+	overridden := make(map[string]bool)
+	for _, e := range cf.entries {
+		if profile != "" && e.profile == profile {
+			overridden[e.key] = true
+		}
+	}
+	sources := make(map[string]string)
+	for _, e := range cf.entries {
+		if e.profile != "" && e.profile != profile {
+			continue
+		}
+		if e.profile == "" && overridden[e.key] {
+			continue
+		}
+		if e.key == "config" || e.key == "profile" {
+			return nil, cf.errorf(e.line, "%q cannot be set from a config file", e.key)
+		}
+		if fs.Lookup(e.key) == nil {
+			return nil, cf.errorf(e.line, "unknown setting %q", e.key)
+		}
+		if explicit[e.key] {
+			continue
+		}
+		if err := fs.Set(e.key, e.value); err != nil {
+			return nil, cf.errorf(e.line, "%s: %v", e.key, err)
+		}
+		sources[e.key] = fmt.Sprintf("%s:%d", cf.path, e.line)
+	}
+	return sources, nil
+}
+
+func (cf *configFile) parseYAML(data []byte) error {
+	type level struct {
+		indent int
+		key    string
+	}
+	var stack []level
+	var listKey []string
+	listIndent := -1
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		raw := stripComment(scanner.Text())
+		text := strings.TrimSpace(raw)
+		if text == "" || text == "---" {
+			continue
+		}
+		indent := len(raw) - len(strings.TrimLeft(raw, " "))
+		if strings.HasPrefix(raw[indent:], "\t") {
+			return cf.errorf(lineNo, "tabs are not allowed for indentation")
+		}
+
+		if item, ok := strings.CutPrefix(text, "- "); ok || text == "-" {
+			if listKey == nil || indent < listIndent {
+				return cf.errorf(lineNo, "list item without a key")
+			}
+			if err := cf.add(lineNo, listKey, unquote(strings.TrimSpace(item))); err != nil {
+				return err
+			}
+			continue
+		}
+		listKey = nil
+
+		key, value, ok := strings.Cut(text, ":")
+		if !ok {
+			return cf.errorf(lineNo, "expected \"key: value\"")
+		}
+		key = unquote(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+		for len(stack) > 0 && stack[len(stack)-1].indent >= indent {
+			stack = stack[:len(stack)-1]
+		}
+		path := make([]string, 0, len(stack)+1)
+		for _, l := range stack {
+			path = append(path, l.key)
+		}
+		path = append(path, key)
+
+		switch {
+		case value == "":
+			// Nested table or block list follows.
+			stack = append(stack, level{indent: indent, key: key})
+			listKey, listIndent = path, indent
+			if len(path) == 2 && path[0] == "profiles" {
+				cf.profiles[key] = true
+			}
+		case strings.HasPrefix(value, "["):
+			values, err := parseInlineList(value)
+			if err != nil {
+				return cf.errorf(lineNo, "%s: %v", key, err)
+			}
+			if err := cf.add(lineNo, path, values...); err != nil {
+				return err
+			}
+		default:
+			if err := cf.add(lineNo, path, unquote(value)); err != nil {
+				return err
+			}
+		}
+	}
+	return scanner.Err()
+}
+
+func (cf *configFile) parseTOML(data []byte) error {
+	var table []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		text := strings.TrimSpace(stripComment(scanner.Text()))
+		if text == "" {
+			continue
+		}
+		if strings.HasPrefix(text, "[") {
+			name, ok := strings.CutSuffix(strings.TrimPrefix(text, "["), "]")
+			if !ok {
+				return cf.errorf(lineNo, "malformed table header")
+			}
+			table = nil
+			for _, part := range strings.Split(name, ".") {
+				table = append(table, unquote(strings.TrimSpace(part)))
+			}
+			if len(table) != 2 || table[0] != "profiles" {
+				return cf.errorf(lineNo, "unexpected table %q (use [profiles.<name>])", name)
+			}
+			cf.profiles[table[1]] = true
+			continue
+		}
+		key, value, ok := strings.Cut(text, "=")
+		if !ok {
+			return cf.errorf(lineNo, "expected \"key = value\"")
+		}
+		key = unquote(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+		path := append(append([]string(nil), table...), key)
+		if strings.HasPrefix(value, "[") {
+			values, err := parseInlineList(value)
+			if err != nil {
+				return cf.errorf(lineNo, "%s: %v", key, err)
+			}
+			if err := cf.add(lineNo, path, values...); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := cf.add(lineNo, path, unquote(value)); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+func (cf *configFile) parseJSON(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	lineAt := func() int {
+		return 1 + bytes.Count(data[:dec.InputOffset()], []byte("\n"))
+	}
+	var parseObject func(path []string) error
+	parseObject = func(path []string) error {
+		if tok, err := dec.Token(); err != nil {
+			return cf.errorf(lineAt(), "%v", err)
+		} else if tok != json.Delim('{') {
+			return cf.errorf(lineAt(), "expected an object")
+		}
+		for dec.More() {
+			tok, err := dec.Token()
+			if err != nil {
+				return cf.errorf(lineAt(), "%v", err)
+			}
+			key := tok.(string)
+			line := lineAt()
+			keyPath := append(append([]string(nil), path...), key)
+			if key == "profiles" && len(path) == 0 || len(path) == 1 && path[0] == "profiles" {
+				if err := parseObject(keyPath); err != nil {
+					return err
+				}
+				if len(path) == 1 {
+					cf.profiles[key] = true
+				}
+				continue
+			}
+			var values []string
+			tok, err = dec.Token()
+			if err != nil {
+				return cf.errorf(line, "%v", err)
+			}
+			if tok == json.Delim('[') {
+				for dec.More() {
+					tok, err := dec.Token()
+					if err != nil {
+						return cf.errorf(lineAt(), "%v", err)
+					}
+					v, err := jsonScalar(tok)
+					if err != nil {
+						return cf.errorf(lineAt(), "%s: %v", key, err)
+					}
+					values = append(values, v)
+				}
+				if _, err := dec.Token(); err != nil {
+					return cf.errorf(lineAt(), "%v", err)
+				}
+			} else {
+				v, err := jsonScalar(tok)
+				if err != nil {
+					return cf.errorf(line, "%s: %v", key, err)
+				}
+				values = append(values, v)
+			}
+			if err := cf.add(line, keyPath, values...); err != nil {
+				return err
+			}
+		}
+		if _, err := dec.Token(); err != nil {
+			return cf.errorf(lineAt(), "%v", err)
+		}
+		return nil
+	}
+	return parseObject(nil)
+}
+
+func jsonScalar(tok json.Token) (string, error) {
+	switch v := tok.(type) {
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	}
+	return "", errors.New("expected a string, number or boolean")
+}
+
+// parseInlineList parses a one-line list such as [a, "b", 3].
+func parseInlineList(s string) ([]string, error) {
+	inner, ok := strings.CutSuffix(strings.TrimPrefix(s, "["), "]")
+	if !ok {
+		return nil, errors.New("lists must open and close on the same line")
+	}
+	var values []string
+	for inner = strings.TrimSpace(inner); inner != ""; inner = strings.TrimSpace(inner) {
+		q := inner[0]
+		if q != '"' && q != '\'' {
+			v, rest, more := strings.Cut(inner, ",")
+			values = append(values, strings.TrimSpace(v))
+			if !more {
+				break
+			}
+			inner = rest
+			continue
+		}
+		end := strings.IndexByte(inner[1:], q)
+		if end < 0 {
+			return nil, errors.New("unterminated string")
+		}
+		values = append(values, inner[1:1+end])
+		inner = strings.TrimSpace(inner[2+end:])
+		if inner != "" && inner[0] != ',' {
+			return nil, errors.New("expected ',' between list items")
+		}
+		inner = strings.TrimPrefix(inner, ",")
+	}
+	return values, nil
+}
+
+// stripComment removes a trailing '#' comment that is not inside quotes.
+func stripComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '"' && s[len(s)-1] == '"' || s[0] == '\'' && s[len(s)-1] == '\'') {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// writeConfigFile writes content to a file named name in a temporary
+// directory and returns its path.
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// entryStrings formats the entries of cf as "line:key=value", with profile
+// keys written as profiles.<name>.<key>.
+func entryStrings(cf *configFile) []string {
+	var s []string
+	for _, e := range cf.entries {
+		key := e.key
+		if e.profile != "" {
+			key = "profiles." + e.profile + "." + key
+		}
+		s = append(s, fmt.Sprintf("%d:%s=%s", e.line, key, e.value))
+	}
+	return s
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		content  string
+		entries  []string
+		profiles []string
+	}{
+		{
+			name: "yaml",
+			file: "c.yaml",
+			content: `# logspeed settings
+---
+k: 20   # trailing comment
+window: "1h"
+search: 'a # not a comment'
+sort: a#b
+normalize: [ipv4-prefix=24, "ipv6-prefix=64"]
+alert:
+  - count>10
+  - 'top-changed'
+profiles:
+  fast:
+    plot-fps: 5
+    normalize: ['lower']
+  "slow one":
+    k: 5
+`,
+			entries: []string{
+				"3:k=20",
+				"4:window=1h",
+				"5:search=a # not a comment",
+				"6:sort=a#b",
+				"7:normalize=ipv4-prefix=24",
+				"7:normalize=ipv6-prefix=64",
+				"9:alert=count>10",
+				"10:alert=top-changed",
+				"13:profiles.fast.plot-fps=5",
+				"14:profiles.fast.normalize=lower",
+				"16:profiles.slow one.k=5",
+			},
+			profiles: []string{"fast", "slow one"},
+		},
+		{
+			name: "yml with an empty profile",
+			file: "c.yml",
+			content: `k: 20
+profiles:
+  empty:
+`,
+			entries:  []string{"1:k=20"},
+			profiles: []string{"empty"},
+		},
+		{
+			name: "toml",
+			file: "c.toml",
+			content: `# logspeed settings
+k = 20
+window = "1h" # trailing comment
+search = 'a # not a comment'
+normalize = ['ipv4-prefix=24', ipv6-prefix=64]
+
+[profiles.fast]
+plot-fps = 5
+[profiles."slow one"]
+k = 5
+`,
+			entries: []string{
+				"2:k=20",
+				"3:window=1h",
+				"4:search=a # not a comment",
+				"5:normalize=ipv4-prefix=24",
+				"5:normalize=ipv6-prefix=64",
+				"8:profiles.fast.plot-fps=5",
+				"10:profiles.slow one.k=5",
+			},
+			profiles: []string{"fast", "slow one"},
+		},
+		{
+			name: "json",
+			file: "c.JSON",
+			content: `{
+  "k": 20,
+  "search": true,
+  "decay": 0.9,
+  "normalize": ["ipv4-prefix=24", "ipv6-prefix=64"],
+  "profiles": {
+    "fast": {
+      "plot-fps": 5
+    },
+    "empty": {}
+  }
+}
+`,
+			entries: []string{
+				"2:k=20",
+				"3:search=true",
+				"4:decay=0.9",
+				"5:normalize=ipv4-prefix=24",
+				"5:normalize=ipv6-prefix=64",
+				"8:profiles.fast.plot-fps=5",
+			},
+			profiles: []string{"empty", "fast"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cf, err := loadConfigFile(writeConfigFile(t, tt.file, tt.content))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := entryStrings(cf); !slices.Equal(got, tt.entries) {
+				t.Errorf("entries:\n got %q\nwant %q", got, tt.entries)
+			}
+			var profiles []string
+			for p := range cf.profiles {
+				profiles = append(profiles, p)
+			}
+			slices.Sort(profiles)
+			if !slices.Equal(profiles, tt.profiles) {
+				t.Errorf("profiles = %q, want %q", profiles, tt.profiles)
+			}
+		})
+	}
+}
+
+func TestLoadConfigFileErrors(t *testing.T) {
+	tests := []struct {
+		file    string
+		content string
+		err     string // the error, after "<path>:"
+	}{
+		{"c.yaml", "k: 1\nk 20\n", "2: expected \"key: value\""},
+		{"c.yaml", "k: 1\n\tk: 2\n", "2: tabs are not allowed for indentation"},
+		{"c.yaml", "# list\n- a\n", "2: list item without a key"},
+		{"c.yaml", "alert:\n  - a\nk: 1\n  - b\n", "4: list item without a key"},
+		{"c.yaml", "k: 1\nwindow:\n  size: 1h\n", "3: unexpected setting \"window.size\" (use top-level keys or profiles.<name>.<key>)"},
+		{"c.yaml", "profiles:\n  fast:\n    deep:\n      k: 1\n", "4: unexpected setting \"profiles.fast.deep.k\" (use top-level keys or profiles.<name>.<key>)"},
+		{"c.yaml", "normalize: [a, b\n", "1: normalize: lists must open and close on the same line"},
+		{"c.yaml", "normalize: ['a, b]\n", "1: normalize: unterminated string"},
+		{"c.yaml", "normalize: ['a' 'b']\n", "1: normalize: expected ',' between list items"},
+		{"c.toml", "k = 1\n[other]\n", "2: unexpected table \"other\" (use [profiles.<name>])"},
+		{"c.toml", "[profiles.a.b]\n", "1: unexpected table \"profiles.a.b\" (use [profiles.<name>])"},
+		{"c.toml", "\n[profiles.fast\n", "2: malformed table header"},
+		{"c.toml", "k: 20\n", "1: expected \"key = value\""},
+		{"c.toml", "[profiles.fast]\nnormalize = [\"a\"\n", "2: normalize: lists must open and close on the same line"},
+		{"c.json", "[1]\n", "1: expected an object"},
+		{"c.json", "{\n  \"k\": 1,\n  \"window\": null\n}\n", "3: window: expected a string, number or boolean"},
+		{"c.json", "{\n  \"window\": {\"size\": \"1h\"}\n}\n", "2: window: expected a string, number or boolean"},
+		{"c.json", "{\n  \"normalize\": [\"a\", [\"b\"]]\n}\n", "2: normalize: expected a string, number or boolean"},
+	}
+	for _, tt := range tests {
+		path := writeConfigFile(t, tt.file, tt.content)
+		_, err := loadConfigFile(path)
+		if want := path + ":" + tt.err; err == nil || err.Error() != want {
+			t.Errorf("%s %q: got error %v, want %s", tt.file, tt.content, err, want)
+		}
+	}
+
+	path := writeConfigFile(t, "c.ini", "k=1\n")
+	if _, err := loadConfigFile(path); err == nil || !strings.Contains(err.Error(), "unknown config format") {
+		t.Errorf("%s: got error %v, want unknown config format", path, err)
+	}
+}
+
+func TestConfigFileApply(t *testing.T) {
+	const content = `k: 20
+window: 1h
+normalize: [lower, strip-query]
+profiles:
+  fast:
+    plot-fps: 5
+    normalize: [path-template]
+`
+	tests := []struct {
+		name      string
+		args      []string
+		profile   string
+		k         int
+		plotFPS   int
+		normalize []string
+		sources   map[string]string // flag name to line
+	}{
+		{
+			name:      "top level",
+			k:         20,
+			plotFPS:   defaultConfig.PlotFPS,
+			normalize: []string{"lower", "strip-query"},
+			sources:   map[string]string{"k": "1", "window": "2", "normalize": "3"},
+		},
+		{
+			name:      "profile replaces lists",
+			profile:   "fast",
+			k:         20,
+			plotFPS:   5,
+			normalize: []string{"path-template"},
+			sources:   map[string]string{"k": "1", "window": "2", "plot-fps": "6", "normalize": "7"},
+		},
+		{
+			name:      "flags take precedence",
+			args:      []string{"-k", "7", "-normalize", "lower"},
+			profile:   "fast",
+			k:         7,
+			plotFPS:   5,
+			normalize: []string{"lower"},
+			sources:   map[string]string{"window": "2", "plot-fps": "6"},
+		},
+	}
+	path := writeConfigFile(t, "c.yaml", content)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := defaultConfig
+			fs := flag.NewFlagSet("", flag.ContinueOnError)
+			registerFlags(fs, &c)
+			if err := fs.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+			sources, err := applyConfigFile(fs, path, tt.profile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.K != tt.k || c.PlotFPS != tt.plotFPS || !slices.Equal(c.Normalize, tt.normalize) {
+				t.Errorf("k=%d plot-fps=%d normalize=%q, want k=%d plot-fps=%d normalize=%q",
+					c.K, c.PlotFPS, c.Normalize, tt.k, tt.plotFPS, tt.normalize)
+			}
+			want := make(map[string]string)
+			for name, line := range tt.sources {
+				want[name] = path + ":" + line
+			}
+			if fmt.Sprint(sources) != fmt.Sprint(want) {
+				t.Errorf("sources = %v, want %v", sources, want)
+			}
+		})
+	}
+}
+
+func TestConfigFileApplyErrors(t *testing.T) {
+	tests := []struct {
+		content string
+		profile string
+		err     string // the error, after "<path>"
+	}{
+		{"k: 20\n", "fast", ": no profile \"fast\""},
+		{"k: 20\nwindows: 1h\n", "", ":2: unknown setting \"windows\""},
+		{"k: 20\nprofile: fast\n", "", ":2: \"profile\" cannot be set from a config file"},
+		{"k: twenty\n", "", ":1: k: parse error"},
+		{"profiles:\n  fast:\n    k: 20\n    window: soon\n", "fast", ":4: window: parse error"},
+		// Settings of other profiles are not checked.
+		{"profiles:\n  fast:\n    k: 20\n  slow:\n    k: slow\n", "fast", ""},
+	}
+	for _, tt := range tests {
+		path := writeConfigFile(t, "c.yaml", tt.content)
+		c := defaultConfig
+		fs := flag.NewFlagSet("", flag.ContinueOnError)
+		fs.SetOutput(io.Discard)
+		registerFlags(fs, &c)
+		_, err := applyConfigFile(fs, path, tt.profile)
+		switch {
+		case tt.err == "" && err != nil:
+			t.Errorf("%q: %v", tt.content, err)
+		case tt.err != "" && (err == nil || !strings.HasPrefix(err.Error(), path+tt.err)):
+			t.Errorf("%q: got error %v, want %s%s", tt.content, err, path, tt.err)
+		}
+	}
+}
+
+func TestLoadConfigReportsFileLine(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		args    []string
+		line    int
+		err     string
+	}{
+		{"profile", "k = 20\n\n[profiles.fast]\nplot-fps = 0\n", []string{"-profile", "fast"}, 4, "-plot-fps must be >= 1"},
+		{"json and access-log", "k = 20\njson = true\n", []string{"-access-log"}, 2, "-json cannot be combined with -access-log"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeConfigFile(t, "c.toml", tt.content)
+			fs := flag.NewFlagSet("", flag.ContinueOnError)
+			fs.SetOutput(io.Discard)
+			_, err := loadConfig(fs, append([]string{"-config", path}, tt.args...))
+			if want := fmt.Sprintf("%s:%d: %s", path, tt.line, tt.err); err == nil || !strings.HasPrefix(err.Error(), want) {
+				t.Errorf("got error %v, want %s...", err, want)
+			}
+		})
+	}
+}
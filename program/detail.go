@@ -0,0 +1,279 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/keilerkonzept/topk"
+	"github.com/keilerkonzept/topk/heap"
+)
+
+var sparkRunes = []rune(" ▁▂▃▄▅▆▇█")
+
+// sparkline renders series as h rows of w columns, each the maximum of the
+// slots it covers, scaled to the series' peak.
+func sparkline(series []float64, w, h int) []string {
+	if w < 1 || h < 1 || len(series) == 0 {
+		return nil
+	}
+	cols := make([]float64, w)
+	resample(series, cols)
+	var peak float64
+	for _, v := range cols {
+		peak = max(peak, v)
+	}
+	steps := len(sparkRunes) - 1
+	rows := make([][]rune, h)
+	for r := range rows {
+		rows[r] = make([]rune, w)
+	}
+	for x, v := range cols {
+		level := 0
+		if peak > 0 {
+			level = int(v / peak * float64(h*steps))
+			if v > 0 {
+				level = max(1, level)
+			}
+		}
+		for r := range rows {
+			// Rows are filled from the bottom.
+			fill := min(steps, max(0, level-(h-1-r)*steps))
+			rows[r][x] = sparkRunes[fill]
+		}
+	}
+	out := make([]string, h)
+	for r, row := range rows {
+		out[r] = string(row)
+	}
+	return out
+}
+
+// rankRun is a stretch of snapshots in which an item held one rank.
+type rankRun struct {
+	rank     int
+	from, to time.Time
+}
+
+// rankRuns returns up to n ranks an item held in the snapshots up to t,
+// newest first. A rank of 0 means it was not in the top-K.
+func (h *leaderboardHistory) rankRuns(item string, t time.Time, n int) []rankRun {
+	var runs []rankRun
+	for i := h.index(t); i >= 0; i-- {
+		s := &h.snapshots[i]
+		rank := 0
+		for j := range s.items {
+			if s.items[j].Item.Item == item {
+				rank = j + 1
+				break
+			}
+		}
+		if last := len(runs) - 1; last >= 0 && runs[last].rank == rank {
+			runs[last].from = s.tick
+			continue
+		}
+		if len(runs) == n {
+			break
+		}
+		runs = append(runs, rankRun{rank: rank, from: s.tick, to: s.tick})
+	}
+	return runs
+}
+
+// firstSeen returns the start of the oldest slot in which item has a count,
+// looking back through the snapshots that hold it up to t.
+func (h *leaderboardHistory) firstSeen(item string, t time.Time, slot time.Duration) time.Time {
+	first := int64(-1)
+	for i := h.index(t); i >= 0; i-- {
+		it := h.snapshots[i].find(item)
+		if it == nil {
+			break
+		}
+		for k, c := range it.counts {
+			if c > 0 {
+				first = it.first + int64(k)
+				break
+			}
+		}
+	}
+	if first < 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, first*int64(slot))
+}
+
+// detailRankRuns is the number of past ranks shown in the detail pane.
+const detailRankRuns = 6
+
+// itemDetail is what the detail pane shows for one item.
+type itemDetail struct {
+	item       heap.Item
+	rank       int // 0 if not in the list
+	tick       time.Time
+	slot       time.Duration
+	series     []float64 // oldest slot first
+	total      uint64
+	rows       int // sketch rows holding the item's fingerprint
+	depth      int
+	inHeap     bool
+	runs       []rankRun
+	firstSeen  time.Time
+	historical bool // shown from the scrub history rather than the sketch
+}
+
+// itemDetail collects the detail pane's data for item from snap, or from the
+// live sketch if snap is nil.
+func (m *model) itemDetail(item heap.Item, rank int, snap *leaderboardSnapshot) itemDetail {
+	d := itemDetail{item: item, rank: rank}
+	if snap != nil {
+		d.tick = snap.tick
+	} else {
+		m.mu.Lock()
+		d.tick = m.latestTick
+		m.mu.Unlock()
+	}
+	m.sketchMu.Lock()
+	s := m.sketch
+	d.series = make([]float64, s.BucketHistoryLength)
+	d.slot = config.WindowSize / time.Duration(s.BucketHistoryLength)
+	if snap != nil {
+		m.history.series(snap, item.Item, d.slot, d.series, false)
+		d.total = snap.total
+		d.historical = true
+	} else {
+		fillSeriesFromSketch(s, item, d.series, false)
+		d.total = m.total.sum
+	}
+	fingerprint := topk.Fingerprint(item.Item)
+	d.depth = s.Depth
+	for i := range s.Depth {
+		b := &s.Buckets[topk.BucketIndex(item.Item, i, s.Width)]
+		if b.Fingerprint == fingerprint && b.CountsSum > 0 {
+			d.rows++
+		}
+	}
+	_, d.inHeap = s.Heap.Index[item.Item]
+	m.sketchMu.Unlock()
+	d.runs = m.history.rankRuns(item.Item, d.tick, detailRankRuns)
+	d.firstSeen = m.history.firstSeen(item.Item, d.tick, d.slot)
+	return d
+}
+
+// slotTime returns the start of series slot i.
+func (d *itemDetail) slotTime(i int) time.Time {
+	return d.tick.Add(-time.Duration(len(d.series)-1-i) * d.slot)
+}
+
+// detailSparkRows caps the height of the detail pane's sparkline.
+const detailSparkRows = 8
+
+// lines renders the detail pane as at most h lines of at most w runes.
+func (d *itemDetail) lines(w, h int) []string {
+	const clock = "15:04:05"
+	var peak float64
+	peakAt, lastAt, firstAt := -1, -1, -1
+	for i, v := range d.series {
+		if v > peak {
+			peak, peakAt = v, i
+		}
+		if v > 0 {
+			lastAt = i
+			if firstAt < 0 {
+				firstAt = i
+			}
+		}
+	}
+
+	count := fmt.Sprintf("count: %d", d.item.Count)
+	if d.total > 0 {
+		count += fmt.Sprintf(" (%.2f%% of %d in window)", 100*float64(d.item.Count)/float64(d.total), d.total)
+	}
+	rank := "rank: not in top-K"
+	if d.rank > 0 {
+		rank = fmt.Sprintf("rank: #%d", d.rank)
+	}
+	var runs []string
+	for _, r := range d.runs {
+		held := "out"
+		if r.rank > 0 {
+			held = fmt.Sprintf("#%d", r.rank)
+		}
+		runs = append(runs, fmt.Sprintf("%s %s–%s", held, r.from.UTC().Format(clock), r.to.UTC().Format(clock)))
+	}
+	heapNote := "not in heap"
+	if d.inHeap {
+		heapNote = "in heap"
+	}
+	sketch := fmt.Sprintf("sketch: %d/%d rows hold its fingerprint, %s", d.rows, d.depth, heapNote)
+	if d.historical {
+		sketch += " (now)"
+	}
+	peakLine := "peak: -"
+	if peakAt >= 0 {
+		peakLine = fmt.Sprintf("peak: %d in %s bucket at %s", int(peak), d.slot, d.slotTime(peakAt).UTC().Format(clock))
+	}
+	first := d.firstSeen
+	if firstAt >= 0 && (first.IsZero() || d.slotTime(firstAt).Before(first)) {
+		first = d.slotTime(firstAt)
+	}
+	seen := "seen: not in window"
+	if lastAt >= 0 {
+		seen = fmt.Sprintf("first seen: %s, last seen: %s", first.UTC().Format(clock), d.slotTime(lastAt).UTC().Format(clock))
+	}
+
+	lines := []string{
+		d.item.Item,
+		count,
+		rank,
+	}
+	if len(runs) > 0 {
+		lines = append(lines, "rank history: "+strings.Join(runs, ", "))
+	}
+	lines = append(lines, sketch, peakLine, seen, "")
+	lines = append(lines, sparkline(d.series, w, min(detailSparkRows, h-len(lines)))...)
+	return lines
+}
+
+// detailView renders the detail pane for the selected item as h lines of
+// width w.
+func (m *model) detailView(w, h int) string {
+	selected, ok := m.list.SelectedItem().(listItem)
+	var lines []string
+	if !ok {
+		lines = []string{"no item selected"}
+	} else {
+		d := m.itemDetail(selected.Item, m.countRank(selected.Item.Item), m.scrubbing())
+		lines = d.lines(w, h)
+	}
+	return padLines(lines, w, h)
+}
+
+// countRank returns item's rank by count on screen, or 0.
+func (m *model) countRank(item string) int {
+	items, _ := m.rankedItems()
+	if snap := m.scrubbing(); snap != nil {
+		items = snap.heapItems()
+	}
+	for i, it := range items {
+		if it.Item == item {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// padLines cuts or pads lines to exactly h lines of width w.
+func padLines(lines []string, w, h int) string {
+	lines = lines[:min(len(lines), h)]
+	for len(lines) < h {
+		lines = append(lines, "")
+	}
+	for i, line := range lines {
+		line = cutLine(line, w)
+		if pad := w - len([]rune(line)); pad > 0 {
+			line += strings.Repeat(" ", pad)
+		}
+		lines[i] = line
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/keilerkonzept/topk/heap"
+)
+
+// snapshotItem is an item's rank and window count at a snapshot, with the
+// per-slot counts of its plot line that earlier snapshots don't already hold.
+type snapshotItem struct {
+	heap.Item
+	first  int64    // absolute bucket history slot of counts[0]
+	counts []uint32 // up to and including the snapshot's slot
+}
+
+// leaderboardSnapshot is the leaderboard at one refresh, sorted by count.
+type leaderboardSnapshot struct {
+	tick  time.Time
+	items []snapshotItem
+	total uint64 // records in the window
+}
+
+// leaderboardHistory keeps the leaderboard snapshots of the last
+// -scrub-history of event time. An item's plot line is stored once and then
+// only extended, so a snapshot costs little more than its K items.
+// Only used by the update loop.
+type leaderboardHistory struct {
+	snapshots []leaderboardSnapshot // oldest first
+	scrubAt   time.Time             // tick of the snapshot shown while scrubbing; zero when live
+}
+
+// historySlot numbers bucket history slots of the given length from the
+// Unix epoch. With one counter per tick these are exactly the sketch's ticks;
+// with a coarser -bucket-history the sketch ages its buckets at staggered
+// ticks, so replayed plot lines can be off by a slot.
+func historySlot(t time.Time, slot time.Duration) int64 {
+	return t.UnixNano() / int64(slot)
+}
+
+// record adds a snapshot of items, sorted by count, at tick, when total
+// records were in the window. series(item, out) fills out with the item's
+// current plot line, oldest slot first.
+func (h *leaderboardHistory) record(tick time.Time, items []heap.Item, total uint64, slot time.Duration, length int, series func(heap.Item, []float64)) {
+	if config.ScrubHistory <= 0 || tick.IsZero() {
+		h.snapshots = nil
+		return
+	}
+	var prev *leaderboardSnapshot
+	if n := len(h.snapshots); n > 0 {
+		prev = &h.snapshots[n-1]
+		if tick.Before(prev.tick) {
+			// Event time went backwards; the history no longer lines up.
+			h.snapshots, prev = nil, nil
+		} else if tick.Equal(prev.tick) {
+			// Keep only the latest refresh of a tick.
+			h.snapshots = h.snapshots[:n-1]
+			prev = nil
+			if n > 1 {
+				prev = &h.snapshots[n-2]
+			}
+		}
+	}
+	cur := historySlot(tick, slot)
+	oldest := cur - int64(length) + 1
+	buf := make([]float64, length)
+	snap := leaderboardSnapshot{tick: tick, items: make([]snapshotItem, len(items)), total: total}
+	for i, item := range items {
+		from := oldest
+		if prev != nil && prev.find(item.Item) != nil {
+			// The previous snapshot's last slot was still filling up.
+			from = max(oldest, historySlot(prev.tick, slot))
+		}
+		series(item, buf)
+		counts := make([]uint32, cur-from+1)
+		for j := range counts {
+			counts[j] = uint32(buf[int(from-oldest)+j])
+		}
+		snap.items[i] = snapshotItem{Item: item, first: from, counts: counts}
+	}
+	h.snapshots = append(h.snapshots, snap)
+
+	keep := tick.Add(-config.ScrubHistory)
+	drop := 0
+	for drop < len(h.snapshots)-1 && h.snapshots[drop].tick.Before(keep) {
+		drop++
+	}
+	if drop > 0 {
+		h.snapshots = append(h.snapshots[:0], h.snapshots[drop:]...)
+	}
+}
+
+func (s *leaderboardSnapshot) find(item string) *snapshotItem {
+	for i := range s.items {
+		if s.items[i].Item.Item == item {
+			return &s.items[i]
+		}
+	}
+	return nil
+}
+
+func (s *leaderboardSnapshot) heapItems() []heap.Item {
+	items := make([]heap.Item, len(s.items))
+	for i, it := range s.items {
+		items[i] = it.Item
+	}
+	return items
+}
+
+// index returns the index of the latest snapshot at or before t, or -1.
+func (h *leaderboardHistory) index(t time.Time) int {
+	return sort.Search(len(h.snapshots), func(i int) bool { return h.snapshots[i].tick.After(t) }) - 1
+}
+
+// current returns the snapshot being scrubbed to, or nil when live.
+func (h *leaderboardHistory) current() *leaderboardSnapshot {
+	if h.scrubAt.IsZero() {
+		return nil
+	}
+	i := h.index(h.scrubAt)
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(h.snapshots) {
+		return nil
+	}
+	return &h.snapshots[i]
+}
+
+// scrub moves the cursor by delta snapshots; moving past the newest snapshot
+// returns to live.
+func (h *leaderboardHistory) scrub(delta int) {
+	n := len(h.snapshots)
+	if n == 0 {
+		return
+	}
+	i := n
+	if !h.scrubAt.IsZero() {
+		i = max(0, h.index(h.scrubAt))
+	}
+	i = max(0, i+delta)
+	if i >= n {
+		h.scrubAt = time.Time{}
+		return
+	}
+	h.scrubAt = h.snapshots[i].tick
+}
+
+// series fills out with the plot line of snap's item, oldest slot first, from
+// snap and the snapshots before it.
+func (h *leaderboardHistory) series(snap *leaderboardSnapshot, item string, slot time.Duration, out []float64, logScale bool) {
+	clear(out)
+	oldest := historySlot(snap.tick, slot) - int64(len(out)) + 1
+	// Newer snapshots hold the final count of a slot that was still filling
+	// up in older ones.
+	done := make([]bool, len(out))
+	for i := h.index(snap.tick); i >= 0; i-- {
+		it := h.snapshots[i].find(item)
+		if it == nil {
+			break
+		}
+		for k, c := range it.counts {
+			pos := it.first + int64(k) - oldest
+			if pos >= 0 && pos < int64(len(out)) && !done[pos] {
+				out[pos], done[pos] = float64(c), true
+			}
+		}
+		if it.first <= oldest {
+			break
+		}
+	}
+	if logScale {
+		for i, v := range out {
+			out[i] = math.Log(max(1, v))
+		}
+	}
+}
+
+// recordSnapshot adds the refreshed leaderboard to the history.
+func (m *model) recordSnapshot() {
+	items, latest := m.rankedItems()
+	m.sketchMu.Lock()
+	slot := config.WindowSize / time.Duration(m.sketch.BucketHistoryLength)
+	length := m.sketch.BucketHistoryLength
+	m.history.record(latest, items, m.total.sum, slot, length, func(item heap.Item, out []float64) {
+		fillSeriesFromSketch(m.sketch, item, out, false)
+	})
+	m.sketchMu.Unlock()
+}
+
+// scrubbing returns the snapshot shown instead of the live leaderboard, if
+// any.
+func (m *model) scrubbing() *leaderboardSnapshot {
+	return m.history.current()
+}
+
+// viewTick is the tick shown by the plot: the scrub cursor or the latest tick.
+func (m *model) viewTick() time.Time {
+	if snap := m.scrubbing(); snap != nil {
+		return snap.tick
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.latestTick
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/bubbles/list"
+	tui "github.com/charmbracelet/bubbletea"
+	"github.com/keilerkonzept/topk/heap"
+)
+
+func TestLeaderboardHistorySeries(t *testing.T) {
+	setConfig(t, func(c *Config) { c.ScrubHistory = time.Hour })
+	const length = 5
+	const slot = time.Minute
+	base := time.Date(2024, 1, 22, 3, 0, 0, 0, time.UTC)
+
+	// counts holds each item's count per absolute slot, as the sketch would.
+	counts := make(map[string]map[int64]uint32)
+	line := func(item string, tick time.Time, out []float64) {
+		oldest := historySlot(tick, slot) - length + 1
+		for j := range out {
+			out[j] = float64(counts[item][oldest+int64(j)])
+		}
+	}
+	// Each step adds to the current slot's counts and then, unless it is
+	// between refreshes, records the items listed as the leaderboard.
+	steps := []struct {
+		minute  int
+		add     map[string]uint32
+		between bool
+	}{
+		{0, map[string]uint32{"a": 3, "b": 1}, false},
+		// The same tick again replaces the snapshot, and b drops off.
+		{0, map[string]uint32{"a": 2}, false},
+		// The slot fills up after its last snapshot.
+		{0, map[string]uint32{"a": 4}, true},
+		{1, map[string]uint32{"a": 1, "b": 4}, false},
+		{1, map[string]uint32{"a": 2, "b": 3}, true},
+		{2, map[string]uint32{"a": 5}, false},
+		{2, map[string]uint32{"b": 7}, true},
+		// b enters again.
+		{3, map[string]uint32{"a": 1, "b": 2}, false},
+		{3, map[string]uint32{"a": 0, "b": 6}, false},
+		{3, map[string]uint32{"a": 8}, true},
+		// A gap longer than the plot line.
+		{9, map[string]uint32{"a": 1, "c": 9}, false},
+		{10, map[string]uint32{"a": 0, "c": 1}, false},
+	}
+
+	var h leaderboardHistory
+	want := make(map[time.Time]map[string][]float64) // plot lines at each tick
+	for _, s := range steps {
+		tick := base.Add(time.Duration(s.minute) * time.Minute)
+		cur := historySlot(tick, slot)
+		var items []heap.Item
+		for item, n := range s.add {
+			if counts[item] == nil {
+				counts[item] = make(map[int64]uint32)
+			}
+			counts[item][cur] += n
+			items = append(items, heap.Item{Item: item, Count: counts[item][cur]})
+		}
+		if s.between {
+			continue
+		}
+		want[tick] = make(map[string][]float64)
+		for _, it := range items {
+			out := make([]float64, length)
+			line(it.Item, tick, out)
+			want[tick][it.Item] = out
+		}
+		h.record(tick, items, 0, slot, length, func(item heap.Item, out []float64) {
+			line(item.Item, tick, out)
+		})
+	}
+
+	if len(h.snapshots) != len(want) {
+		t.Fatalf("%d snapshots, want one per tick (%d)", len(h.snapshots), len(want))
+	}
+	out := make([]float64, length)
+	for i := range h.snapshots {
+		snap := &h.snapshots[i]
+		if len(snap.items) != len(want[snap.tick]) {
+			t.Errorf("snapshot at %s has %d items, want %d", snap.tick.Format("15:04"), len(snap.items), len(want[snap.tick]))
+		}
+		for item, line := range want[snap.tick] {
+			h.series(snap, item, slot, out, false)
+			if !slices.Equal(out, line) {
+				t.Errorf("series of %s at %s = %v, want %v", item, snap.tick.Format("15:04"), out, line)
+			}
+			h.series(snap, item, slot, out, true)
+			for j, v := range line {
+				if out[j] != math.Log(max(1, v)) {
+					t.Errorf("log series of %s at %s = %v, want log of %v", item, snap.tick.Format("15:04"), out, line)
+					break
+				}
+			}
+		}
+	}
+}
+
+func TestLeaderboardHistoryRecord(t *testing.T) {
+	base := time.Date(2024, 1, 22, 3, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		scrub   time.Duration
+		minutes []int
+		want    []int // minutes of the snapshots kept
+	}{
+		{"disabled", 0, []int{0, 1, 2}, nil},
+		{"keeps -scrub-history", 2 * time.Minute, []int{0, 1, 2, 3, 4, 5}, []int{3, 4, 5}},
+		{"keeps the latest refresh of a tick", time.Hour, []int{0, 1, 1, 2}, []int{0, 1, 2}},
+		{"keeps the newest snapshot after a gap", time.Minute, []int{0, 1, 30}, []int{30}},
+		{"restarts when time goes backwards", time.Hour, []int{5, 6, 2, 3}, []int{2, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setConfig(t, func(c *Config) { c.ScrubHistory = tt.scrub })
+			var h leaderboardHistory
+			for _, minute := range tt.minutes {
+				tick := base.Add(time.Duration(minute) * time.Minute)
+				h.record(tick, []heap.Item{{Item: "a", Count: 1}}, 1, time.Minute, 3, func(_ heap.Item, out []float64) {
+					clear(out)
+					out[len(out)-1] = 1
+				})
+			}
+			var got []int
+			for _, snap := range h.snapshots {
+				got = append(got, int(snap.tick.Sub(base)/time.Minute))
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("snapshots at minutes %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScrubMode(t *testing.T) {
+	setConfig(t, func(c *Config) {
+		c.ScrubHistory = time.Hour
+		c.SearchEnabled = true
+	})
+	m := newModel(newSketch(&config))
+	m.width, m.height = 100, 30
+	m.layout()
+	base := time.Date(2024, 1, 22, 3, 0, 0, 0, time.UTC)
+	for minute := range 3 {
+		tick := base.Add(time.Duration(minute) * time.Minute)
+		m.history.record(tick, []heap.Item{{Item: "a", Count: 1}}, 1, time.Minute, 3, func(_ heap.Item, out []float64) { clear(out) })
+	}
+	items := make([]list.Item, 40)
+	for i := range items {
+		items[i] = listItem{Item: heap.Item{Item: fmt.Sprint(i), Count: 1}}
+	}
+	m.list.SetItems(items)
+	if m.list.Paginator.TotalPages < 2 {
+		t.Fatalf("the list has %d page, want several", m.list.Paginator.TotalPages)
+	}
+
+	press := func(msg tui.KeyMsg) { m.Update(msg) }
+	left := tui.KeyMsg{Type: tui.KeyLeft}
+	right := tui.KeyMsg{Type: tui.KeyRight}
+	scrubbedTo := func(step string, want int) {
+		t.Helper()
+		got := -1
+		if snap := m.scrubbing(); snap != nil {
+			got = int(snap.tick.Sub(base) / time.Minute)
+		}
+		if got != want {
+			t.Errorf("after %s: scrubbed to minute %d, want %d (-1 = live)", step, got, want)
+		}
+	}
+
+	// Outside scrub mode the arrow keys page the list.
+	press(right)
+	if page := m.list.Paginator.Page; page != 1 {
+		t.Errorf("→ outside scrub mode: list page %d, want 1", page)
+	}
+	press(left)
+	if page := m.list.Paginator.Page; page != 0 {
+		t.Errorf("← outside scrub mode: list page %d, want 0", page)
+	}
+	scrubbedTo("paging", -1)
+
+	press(tui.KeyMsg{Type: tui.KeyRunes, Runes: []rune("[")})
+	if !m.scrubMode {
+		t.Fatal("[ did not enter scrub mode")
+	}
+	scrubbedTo("[", 2)
+	press(left)
+	scrubbedTo("←", 1)
+	press(left)
+	press(left)
+	scrubbedTo("← past the oldest", 0)
+	press(right)
+	scrubbedTo("→", 1)
+	press(right)
+	press(right)
+	scrubbedTo("→ past the newest", -1)
+	if !m.scrubMode {
+		t.Error("stepping to live left scrub mode")
+	}
+	press(left)
+	scrubbedTo("← from live", 2)
+
+	press(tui.KeyMsg{Type: tui.KeyEsc})
+	if m.scrubMode {
+		t.Error("esc did not leave scrub mode")
+	}
+	scrubbedTo("esc", -1)
+	press(left)
+	scrubbedTo("← after esc", -1)
+}
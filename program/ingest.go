@@ -0,0 +1,131 @@
+package main
+
+import (
+	"strings"
+	"sync"
+	"time"
+	"unsafe"
+)
+
+// ingestFlushInterval bounds how long a record may wait in the ingest buffer
+// before it reaches the sketch.
+const ingestFlushInterval = 10 * time.Millisecond
+
+type ingestRecord struct {
+	start, end int // item bytes in ingestBuffer.arena
+	count      uint32
+}
+
+// ingestBuffer batches records so that the sketch lock is taken once per
+// batch instead of once per record. Renderers then contend with at most one
+// lock acquisition per -ingest-batch records.
+//
+// Item bytes are copied into a reused arena rather than kept as strings, so
+// readers can hand over views of their scan buffers and buffering a record
+// allocates nothing.
+type ingestBuffer struct {
+	mu      sync.Mutex
+	records []ingestRecord
+	arena   []byte
+	size    int
+	oldest  time.Time
+	first   time.Time // arrival of the first record ever buffered
+}
+
+func newIngestBuffer(size int) *ingestBuffer {
+	size = max(1, size)
+	return &ingestBuffer{size: size, records: make([]ingestRecord, 0, size)}
+}
+
+// ingest counts one record of item into the sketch, buffering it until the
+// batch is full or ingestFlushInterval has passed. item is copied, so it may
+// be a view of a buffer that the caller reuses (see unsafeString).
+func (m *model) ingest(item string, inc uint32) {
+	now := time.Now()
+	b := m.ingestBuf
+	b.mu.Lock()
+	if len(b.records) == 0 {
+		b.oldest = now
+		if b.first.IsZero() {
+			b.first = now
+		}
+	}
+	start := len(b.arena)
+	b.arena = append(b.arena, item...)
+	b.records = append(b.records, ingestRecord{start: start, end: len(b.arena), count: inc})
+	if len(b.records) >= b.size || now.Sub(b.oldest) >= ingestFlushInterval {
+		m.flushIngestLocked(now)
+	}
+	b.mu.Unlock()
+}
+
+// flushIngest moves all buffered records into the sketch. It is called before
+// ticking, so records stay in their bucket, and before rendering.
+func (m *model) flushIngest() {
+	b := m.ingestBuf
+	b.mu.Lock()
+	m.flushIngestLocked(time.Now())
+	b.mu.Unlock()
+}
+
+// flushIngestLocked is flushIngest with m.ingestBuf.mu held.
+func (m *model) flushIngestLocked(now time.Time) {
+	b := m.ingestBuf
+	if len(b.records) == 0 {
+		return
+	}
+	m.sketchMu.Lock()
+	for _, r := range b.records {
+		item := unsafeString(b.arena[r.start:r.end])
+		m.addLocked(item, r.count)
+		m.total.add(r.count)
+	}
+	m.sketchMu.Unlock()
+	if m.verifier != nil {
+		// Still under the ingest lock, so ticks see the same records here as
+		// in the sketch.
+		exact := m.verifier.exact
+		exact.mu.Lock()
+		for _, r := range b.records {
+			exact.addLocked(unsafeString(b.arena[r.start:r.end]), r.count)
+		}
+		exact.mu.Unlock()
+	}
+	m.metrics.observeIngest(now, len(b.records))
+	for _, r := range b.records {
+		m.metrics.observeItem(unsafeString(b.arena[r.start:r.end]))
+	}
+	b.records = b.records[:0]
+	b.arena = b.arena[:0]
+}
+
+// addLocked adds item to the sketch with m.sketchMu held. item may be a
+// temporary view: the heap keeps its own copy of the keys it stores, made
+// only when an item enters the top-K.
+func (m *model) addLocked(item string, count uint32) {
+	h := m.sketch.Heap
+	if i, ok := h.Index[item]; ok {
+		m.sketch.Add(h.Items[i].Item, count)
+		return
+	}
+	if m.sketch.Add(item, count) {
+		m.ownHeapKeyLocked(item)
+	}
+}
+
+// ownHeapKeyLocked replaces the heap key of an item that just entered the
+// top-K with a copy, since item may be a temporary view.
+func (m *model) ownHeapKeyLocked(item string) {
+	h := m.sketch.Heap
+	i := h.Index[item]
+	owned := strings.Clone(item)
+	delete(h.Index, item)
+	h.Index[owned] = i
+	h.Items[i].Item = owned
+}
+
+// unsafeString returns a string sharing b's memory. It is only valid until b
+// is modified, so it must not be retained.
+func unsafeString(b []byte) string {
+	return unsafe.String(unsafe.SliceData(b), len(b))
+}
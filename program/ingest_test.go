@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// BenchmarkIngestContention ingests records while a simulated plot refresh
+// reads the sketch at 15 and 30 FPS, for several -ingest-batch sizes. One op
+// is one record.
+func BenchmarkIngestContention(b *testing.B) {
+	items := testItems(1 << 16)
+	for _, fps := range []int{15, 30} {
+		for _, batch := range []int{1, 16, 64, 256, 1024} {
+			b.Run(fmt.Sprintf("fps=%d/batch=%d", fps, batch), func(b *testing.B) {
+				setConfig(b, func(c *Config) {
+					c.K = 50
+					c.TickSize = time.Minute
+					c.WindowSize = time.Hour
+					c.IngestBatch = batch
+				})
+				m := newModel(newSketch(&config))
+				stop := startBenchRenderer(m, fps)
+				defer stop()
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := range b.N {
+					m.ingest(items[i%len(items)], 1)
+				}
+				m.flushIngest()
+				b.StopTimer()
+				b.ReportMetric(float64(b.N)/b.Elapsed().Seconds(), "rec/s")
+			})
+		}
+	}
+}
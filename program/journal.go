@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/keilerkonzept/topk/heap"
+)
+
+// Kinds of leaderboard change.
+const (
+	journalEnter = "enter" // the item entered the top-K
+	journalExit  = "exit"  // the item left the top-K
+	journalRank  = "rank"  // the item moved to another rank
+)
+
+// journalEvent is a leaderboard change, as written to -journal.
+type journalEvent struct {
+	Time     time.Time `json:"time"` // event time of the refresh that saw the change
+	Kind     string    `json:"kind"`
+	Item     string    `json:"item"`
+	Rank     int       `json:"rank,omitempty"`      // new rank, from 1; 0 after an exit
+	PrevRank int       `json:"prev_rank,omitempty"` // 0 on entry
+	Count    uint32    `json:"count"`
+	Held     string    `json:"held,omitempty"` // how long the item held its previous rank
+}
+
+// journalKeep is the number of events kept for the journal pane.
+const journalKeep = 1000
+
+type journalPosition struct {
+	rank  int
+	count uint32
+	since time.Time // event time at which the item reached rank
+}
+
+// journal diffs successive rankings into enter, exit and rank-change events.
+// Only used by the update loop.
+type journal struct {
+	prev   map[string]journalPosition
+	events []journalEvent // most recent last
+	out    *os.File
+	w      *bufio.Writer
+	enc    *json.Encoder
+}
+
+// newMemoryJournal creates a journal that is only kept for the journal pane.
+func newMemoryJournal() *journal {
+	return &journal{prev: make(map[string]journalPosition)}
+}
+
+// newJournal creates a journal that also appends its events to path as
+// NDJSON, if path is set.
+func newJournal(path string) (*journal, error) {
+	j := newMemoryJournal()
+	if path == "" {
+		return j, nil
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	j.out = f
+	j.w = bufio.NewWriter(f)
+	j.enc = json.NewEncoder(j.w)
+	j.enc.SetEscapeHTML(false)
+	return j, nil
+}
+
+// update records the changes from the previous ranking to items, sorted by
+// count, at event time t.
+func (j *journal) update(t time.Time, items []heap.Item) error {
+	var changes []journalEvent
+	next := make(map[string]journalPosition, len(items))
+	for i, item := range items {
+		rank := i + 1
+		cur := journalPosition{rank: rank, count: item.Count, since: t}
+		prev, ok := j.prev[item.Item]
+		switch {
+		case !ok:
+			changes = append(changes, journalEvent{Time: t, Kind: journalEnter, Item: item.Item, Rank: rank, Count: item.Count})
+		case prev.rank != rank:
+			changes = append(changes, journalEvent{Time: t, Kind: journalRank, Item: item.Item, Rank: rank, PrevRank: prev.rank, Count: item.Count, Held: heldFor(t, prev.since)})
+		default:
+			cur.since = prev.since
+		}
+		next[item.Item] = cur
+	}
+	var exits []journalEvent
+	for item, prev := range j.prev {
+		if _, ok := next[item]; !ok {
+			exits = append(exits, journalEvent{Time: t, Kind: journalExit, Item: item, PrevRank: prev.rank, Count: prev.count, Held: heldFor(t, prev.since)})
+		}
+	}
+	sort.Slice(exits, func(a, b int) bool { return exits[a].PrevRank < exits[b].PrevRank })
+	changes = append(changes, exits...)
+	j.prev = next
+	if len(changes) == 0 {
+		return nil
+	}
+	j.events = append(j.events, changes...)
+	if n := len(j.events) - journalKeep; n > 0 {
+		j.events = append(j.events[:0], j.events[n:]...)
+	}
+	if j.enc == nil {
+		return nil
+	}
+	for _, e := range changes {
+		if err := j.enc.Encode(e); err != nil {
+			return err
+		}
+	}
+	return j.w.Flush()
+}
+
+func heldFor(t, since time.Time) string {
+	if d := t.Sub(since); d > 0 {
+		return d.String()
+	}
+	return ""
+}
+
+func (j *journal) close() error {
+	if j == nil || j.out == nil {
+		return nil
+	}
+	if err := j.w.Flush(); err != nil {
+		j.out.Close()
+		return err
+	}
+	return j.out.Close()
+}
+
+// view renders the most recent events, newest first, as h lines of width w.
+func (j *journal) view(w, h int) string {
+	lines := make([]string, 0, h)
+	for i := len(j.events) - 1; i >= 0 && len(lines) < h; i-- {
+		lines = append(lines, j.events[i].line())
+	}
+	if len(lines) == 0 {
+		lines = append(lines, "no leaderboard changes yet")
+	}
+	return padLines(lines, w, h)
+}
+
+func (e journalEvent) line() string {
+	ts := e.Time.UTC().Format("15:04:05")
+	switch e.Kind {
+	case journalEnter:
+		return fmt.Sprintf("%s + %s entered at #%d (%d)", ts, e.Item, e.Rank, e.Count)
+	case journalExit:
+		return fmt.Sprintf("%s - %s left from #%d after %s", ts, e.Item, e.PrevRank, orDash(e.Held))
+	}
+	arrow := "↑"
+	if e.Rank > e.PrevRank {
+		arrow = "↓"
+	}
+	return fmt.Sprintf("%s %s %s #%d → #%d (%d)", ts, arrow, e.Item, e.PrevRank, e.Rank, e.Count)
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// cutLine shortens s to at most w runes.
+func cutLine(s string, w int) string {
+	if r := []rune(s); w > 0 && len(r) > w {
+		return string(r[:w-1]) + "…"
+	}
+	return s
+}
+
+// rankedItems returns the leaderboard sorted by count and the latest tick.
+func (m *model) rankedItems() ([]heap.Item, time.Time) {
+	m.mu.Lock()
+	items := cloneItems(m.listItems)
+	latest := m.latestTick
+	m.mu.Unlock()
+	// The list may be ordered by rise.
+	insertionSort(items)
+	return items, latest
+}
+
+// updateJournal records the changes made by the latest leaderboard refresh.
+func (m *model) updateJournal() {
+	items, latest := m.rankedItems()
+	if latest.IsZero() {
+		// Real-time input has no tick until -tick has passed; its records'
+		// event time is their arrival, so stamp changes with the first one.
+		latest = m.firstRecordTime()
+	}
+	if err := m.journal.update(latest, items); err != nil {
+		m.setNotice("journal: " + err.Error())
+	}
+}
+
+// firstRecordTime returns when the first record was read, or the zero time.
+func (m *model) firstRecordTime() time.Time {
+	b := m.ingestBuf
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.first
+}
+
+func (m *model) toggleJournal() {
+	m.showJournal = !m.showJournal
+}
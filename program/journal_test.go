@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/keilerkonzept/topk/heap"
+)
+
+func TestJournalUpdate(t *testing.T) {
+	t0 := time.Date(2024, 1, 22, 3, 0, 0, 0, time.UTC)
+	at := func(minutes int) time.Time { return t0.Add(time.Duration(minutes) * time.Minute) }
+	items := func(kv ...any) []heap.Item {
+		var out []heap.Item
+		for i := 0; i < len(kv); i += 2 {
+			out = append(out, heap.Item{Item: kv[i].(string), Count: uint32(kv[i+1].(int))})
+		}
+		return out
+	}
+	steps := []struct {
+		t     time.Time
+		items []heap.Item
+		want  []journalEvent
+	}{
+		{at(0), items("a", 10, "b", 5), []journalEvent{
+			{Time: at(0), Kind: journalEnter, Item: "a", Rank: 1, Count: 10},
+			{Time: at(0), Kind: journalEnter, Item: "b", Rank: 2, Count: 5},
+		}},
+		{at(1), items("b", 12, "a", 10, "c", 3), []journalEvent{
+			{Time: at(1), Kind: journalRank, Item: "b", Rank: 1, PrevRank: 2, Count: 12, Held: "1m0s"},
+			{Time: at(1), Kind: journalRank, Item: "a", Rank: 2, PrevRank: 1, Count: 10, Held: "1m0s"},
+			{Time: at(1), Kind: journalEnter, Item: "c", Rank: 3, Count: 3},
+		}},
+		// Exits come after the other changes.
+		{at(3), items("b", 13, "c", 11), []journalEvent{
+			{Time: at(3), Kind: journalRank, Item: "c", Rank: 2, PrevRank: 3, Count: 11, Held: "2m0s"},
+			{Time: at(3), Kind: journalExit, Item: "a", PrevRank: 2, Count: 10, Held: "2m0s"},
+		}},
+		// Counts change but ranks don't; b has held #1 since at(1).
+		{at(4), items("b", 20, "c", 15), nil},
+		// Exits are ordered by their previous rank.
+		{at(6), nil, []journalEvent{
+			{Time: at(6), Kind: journalExit, Item: "b", PrevRank: 1, Count: 20, Held: "5m0s"},
+			{Time: at(6), Kind: journalExit, Item: "c", PrevRank: 2, Count: 15, Held: "3m0s"},
+		}},
+		// A returning item enters again.
+		{at(7), items("a", 1), []journalEvent{
+			{Time: at(7), Kind: journalEnter, Item: "a", Rank: 1, Count: 1},
+		}},
+	}
+
+	path := filepath.Join(t.TempDir(), "journal.ndjson")
+	j, err := newJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var all []journalEvent
+	for i, s := range steps {
+		before := len(j.events)
+		if err := j.update(s.t, s.items); err != nil {
+			t.Fatalf("step %d: %v", i, err)
+		}
+		if got := j.events[before:]; !slices.Equal(got, s.want) {
+			t.Errorf("step %d: events\n%+v\nwant\n%+v", i, got, s.want)
+		}
+		all = append(all, s.want...)
+	}
+	if err := j.close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var written []journalEvent
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var e journalEvent
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			t.Fatalf("%q: %v", sc.Text(), err)
+		}
+		written = append(written, e)
+	}
+	if !slices.EqualFunc(written, all, func(a, b journalEvent) bool {
+		a.Time, b.Time = a.Time.UTC(), b.Time.UTC()
+		return a == b
+	}) {
+		t.Errorf("-journal file has\n%+v\nwant\n%+v", written, all)
+	}
+}
+
+func TestJournalBeforeFirstTick(t *testing.T) {
+	setConfig(t, func(c *Config) {
+		c.K = 10
+		c.Width, c.Depth = 1024, 3
+		c.TickSize = time.Minute
+		c.WindowSize = 10 * time.Minute
+		c.Replay = false
+	})
+	m := newModel(newSketch(&config))
+	before := time.Now()
+	m.ingest("a", 1)
+	m.flushIngest()
+	m.sketchMu.Lock()
+	m.listItems = m.sketch.SortedSlice()
+	m.sketchMu.Unlock()
+	if !m.latestTick.IsZero() {
+		t.Fatalf("latest tick = %v before any tick", m.latestTick)
+	}
+	m.updateJournal()
+
+	if len(m.journal.events) != 1 {
+		t.Fatalf("events = %+v, want one entry", m.journal.events)
+	}
+	e := m.journal.events[0]
+	if e.Kind != journalEnter || e.Time.Before(before) || e.Time.After(time.Now()) {
+		t.Errorf("event = %+v, want an entry stamped with the record's arrival", e)
+	}
+	if !e.Time.Equal(m.firstRecordTime()) {
+		t.Errorf("event time = %v, want the first record's %v", e.Time, m.firstRecordTime())
+	}
+}
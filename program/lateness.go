@@ -0,0 +1,193 @@
+package main
+
+import (
+	"container/heap"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/keilerkonzept/topk"
+)
+
+// What to do with a record whose tick has already been closed.
+const (
+	latePolicyDrop          = "drop"            // don't count it
+	latePolicyCountNow      = "count-now"       // count it in the current tick
+	latePolicyCountInBucket = "count-in-bucket" // count it in its own tick while that is in the window
+)
+
+type lateCounters struct {
+	reordered  atomic.Uint64 // out-of-order records put back in order by the reorder buffer
+	dropped    atomic.Uint64
+	countedNow atomic.Uint64
+	backfilled atomic.Uint64
+}
+
+func (c *lateCounters) String() string {
+	return fmt.Sprintf("late: %d reordered, %d dropped, %d counted now, %d back-filled",
+		c.reordered.Load(), c.dropped.Load(), c.countedNow.Load(), c.backfilled.Load())
+}
+
+// showLateStats reports whether STATS has a line for late records.
+func showLateStats() bool {
+	return config.AllowedLateness > 0 || config.LatePolicy != latePolicyCountNow
+}
+
+type pendingEvent struct {
+	time  time.Time
+	seq   uint64
+	item  string
+	count uint32
+}
+
+// eventQueue is a min-heap of records by event time, in arrival order for
+// equal times.
+type eventQueue []pendingEvent
+
+func (q eventQueue) Len() int { return len(q) }
+func (q eventQueue) Less(i, j int) bool {
+	if !q[i].time.Equal(q[j].time) {
+		return q[i].time.Before(q[j].time)
+	}
+	return q[i].seq < q[j].seq
+}
+func (q eventQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+func (q *eventQueue) Push(x any)   { *q = append(*q, x.(pendingEvent)) }
+func (q *eventQueue) Pop() any {
+	old := *q
+	e := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return e
+}
+
+// countEvent counts a timestamped record. With -allowed-lateness, records
+// are held until event time has moved that far past them, and released in
+// time order; item is copied if so.
+func (m *model) countEvent(clock *eventClock, t time.Time, item string, count uint32) {
+	if config.AllowedLateness <= 0 {
+		m.emitEvent(clock, t, item, count)
+		return
+	}
+	clock.pendingMu.Lock()
+	defer clock.pendingMu.Unlock()
+	if t.Before(clock.maxSeen) {
+		if !t.Before(clock.maxSeen.Add(-config.AllowedLateness)) {
+			m.late.reordered.Add(1)
+		}
+	} else {
+		clock.maxSeen = t
+	}
+	heap.Push(&clock.pending, pendingEvent{time: t, seq: clock.seq, item: strings.Clone(item), count: count})
+	clock.seq++
+	m.releaseEvents(clock, clock.maxSeen.Add(-config.AllowedLateness))
+}
+
+// releaseEvents counts the held records up to watermark, in time order, and
+// reports whether there were any. Called with clock.pendingMu held.
+func (m *model) releaseEvents(clock *eventClock, watermark time.Time) bool {
+	released := false
+	for len(clock.pending) > 0 && !clock.pending[0].time.After(watermark) {
+		e := heap.Pop(&clock.pending).(pendingEvent)
+		m.emitEvent(clock, e.time, e.item, e.count)
+		released = true
+	}
+	return released
+}
+
+// drainEvents releases the records still held in the reorder buffer at the
+// end of the input.
+func (m *model) drainEvents(clock *eventClock) {
+	clock.pendingMu.Lock()
+	defer clock.pendingMu.Unlock()
+	for len(clock.pending) > 0 {
+		e := heap.Pop(&clock.pending).(pendingEvent)
+		m.emitEvent(clock, e.time, e.item, e.count)
+	}
+}
+
+// emitEvent advances event time to t and counts the record, or applies
+// -late-policy if t's tick has already been closed.
+func (m *model) emitEvent(clock *eventClock, t time.Time, item string, count uint32) {
+	item = m.normalizer.Load().apply(item)
+	if last := clock.lastTick(); !last.IsZero() {
+		if age := int(last.Sub(t.Truncate(config.TickSize)) / config.TickSize); age > 0 {
+			if behind := last.Sub(t); behind > config.WindowSize && !clock.behind {
+				// Time went backwards rather than a record arriving late.
+				if config.NewSession {
+					m.recordClockEvent(clockBackwards, t.Truncate(config.TickSize), behind)
+					m.restartSession(clock, t)
+					m.observeEventTime(clock, t)
+					m.ingest(item, count)
+					return
+				}
+				m.recordClockEvent(clockBackwards, last, behind)
+				clock.behind = true
+			}
+			m.countLate(item, count, age)
+			return
+		}
+	}
+	clock.behind = false
+	m.observeEventTime(clock, t)
+	m.ingest(item, count)
+}
+
+// countLate handles a record that is age ticks behind the current tick.
+func (m *model) countLate(item string, count uint32, age int) {
+	switch config.LatePolicy {
+	case latePolicyDrop:
+		m.late.dropped.Add(1)
+	case latePolicyCountInBucket:
+		if age >= int(config.WindowSize/config.TickSize) {
+			// Its tick has already left the window.
+			m.late.dropped.Add(1)
+			return
+		}
+		m.addAtAge(item, count, age)
+		m.late.backfilled.Add(1)
+	default:
+		m.ingest(item, count)
+		m.late.countedNow.Add(1)
+	}
+}
+
+// addAtAge adds count to the sketch counters of the tick age ticks ago and
+// updates the item's rank. Buckets held by a different item are left alone:
+// decaying an old counter has no meaning for the HeavyKeeper.
+func (m *model) addAtAge(item string, count uint32, age int) {
+	m.flushIngest()
+	m.sketchMu.Lock()
+	s := m.sketch
+	slot := age * s.BucketHistoryLength / s.WindowSize
+	fingerprint := topk.Fingerprint(item)
+	var maxSum uint32
+	for i := range s.Depth {
+		b := &s.Buckets[topk.BucketIndex(item, i, s.Width)]
+		switch {
+		case b.CountsSum == 0:
+			b.Fingerprint = fingerprint
+			clear(b.Counts)
+		case b.Fingerprint != fingerprint:
+			continue
+		}
+		b.Counts[(int(b.First)+slot)%len(b.Counts)] += count
+		b.CountsSum += count
+		maxSum = max(maxSum, b.CountsSum)
+	}
+	if maxSum > 0 {
+		h := s.Heap
+		if i, ok := h.Index[item]; ok {
+			h.Update(h.Items[i].Item, fingerprint, maxSum)
+		} else if h.Update(item, fingerprint, maxSum) {
+			m.ownHeapKeyLocked(item)
+		}
+	}
+	m.total.addAt(count, age)
+	if m.verifier != nil {
+		m.verifier.exact.addAt(item, count, age)
+	}
+	m.sketchMu.Unlock()
+	m.metrics.observeIngest(time.Time{}, 1)
+	m.metrics.observeItem(item)
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"container/heap"
+	"testing"
+	"time"
+)
+
+func TestEventQueueOrder(t *testing.T) {
+	base := time.Date(2024, 1, 22, 3, 56, 0, 0, time.UTC)
+	arrivals := []struct {
+		after time.Duration
+		item  string
+	}{
+		{3 * time.Second, "c"},
+		{time.Second, "a1"},
+		{2 * time.Second, "b"},
+		{time.Second, "a2"},
+		{0, "first"},
+		{time.Second, "a3"},
+	}
+	var q eventQueue
+	for seq, a := range arrivals {
+		heap.Push(&q, pendingEvent{time: base.Add(a.after), seq: uint64(seq), item: a.item})
+	}
+	want := []string{"first", "a1", "a2", "a3", "b", "c"}
+	for i, item := range want {
+		if e := heap.Pop(&q).(pendingEvent); e.item != item {
+			t.Errorf("pop %d = %s, want %s", i, e.item, item)
+		}
+	}
+	if q.Len() != 0 {
+		t.Errorf("%d events left", q.Len())
+	}
+}
+
+func TestLatePolicies(t *testing.T) {
+	type record struct {
+		at   time.Duration // event time after the start
+		item string
+	}
+	// Ticks are a minute and the window ten. Record "late" arrives after
+	// event time reached 00:05 but belongs to 00:00; once event time reaches
+	// 00:10, its own tick has left the window while 00:05 has not.
+	lateInput := []record{
+		{5 * time.Minute, "a"},
+		{30 * time.Second, "late"},
+		{5*time.Minute + time.Second, "a"},
+	}
+	tests := []struct {
+		name     string
+		lateness time.Duration
+		policy   string
+		input    []record
+		end      time.Duration // event time reached after the input
+		counters [4]uint64     // reordered, dropped, counted now, back-filled
+		late     uint32        // count of "late" at the end
+		total    uint64        // records in the window at the end
+	}{
+		{
+			name:     "count-now",
+			policy:   latePolicyCountNow,
+			input:    lateInput,
+			end:      10 * time.Minute,
+			counters: [4]uint64{0, 0, 1, 0},
+			late:     1,
+			total:    3,
+		},
+		{
+			name:     "drop",
+			policy:   latePolicyDrop,
+			input:    lateInput,
+			end:      10 * time.Minute,
+			counters: [4]uint64{0, 1, 0, 0},
+			late:     0,
+			total:    2,
+		},
+		{
+			name:     "count-in-bucket, still in the window",
+			policy:   latePolicyCountInBucket,
+			input:    lateInput,
+			end:      9 * time.Minute,
+			counters: [4]uint64{0, 0, 0, 1},
+			late:     1,
+			total:    3,
+		},
+		{
+			name:     "count-in-bucket, aged out with its tick",
+			policy:   latePolicyCountInBucket,
+			input:    lateInput,
+			end:      10 * time.Minute,
+			counters: [4]uint64{0, 0, 0, 1},
+			late:     0,
+			total:    2,
+		},
+		{
+			name:   "count-in-bucket, older than the window",
+			policy: latePolicyCountInBucket,
+			input: []record{
+				{15 * time.Minute, "a"},
+				{4 * time.Minute, "late"},
+			},
+			end:      15 * time.Minute,
+			counters: [4]uint64{0, 1, 0, 0},
+			late:     0,
+			total:    1,
+		},
+		{
+			name:     "allowed lateness puts records back in order",
+			lateness: 6 * time.Minute,
+			policy:   latePolicyDrop,
+			input:    lateInput,
+			end:      9 * time.Minute,
+			counters: [4]uint64{1, 0, 0, 0},
+			late:     1,
+			total:    3,
+		},
+		{
+			name:     "records later than the allowed lateness",
+			lateness: 2 * time.Minute,
+			policy:   latePolicyDrop,
+			input: []record{
+				{5 * time.Minute, "a"},
+				// Releases the first record.
+				{8 * time.Minute, "a"},
+				{30 * time.Second, "late"},
+			},
+			end:      9 * time.Minute,
+			counters: [4]uint64{0, 1, 0, 0},
+			late:     0,
+			total:    2,
+		},
+	}
+	start := time.Date(2024, 1, 22, 3, 0, 0, 0, time.UTC)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setConfig(t, func(c *Config) {
+				c.K = 10
+				c.TickSize = time.Minute
+				c.WindowSize = 10 * time.Minute
+				c.HistoryLength = 0
+				c.AllowedLateness = tt.lateness
+				c.LatePolicy = tt.policy
+				c.Replay = false
+			})
+			m := newModel(newSketch(&config))
+			clock := m.newEventClock()
+			for _, r := range tt.input {
+				m.countEvent(clock, start.Add(r.at), r.item, 1)
+			}
+			m.drainEvents(clock)
+			m.observeEventTime(clock, start.Add(tt.end))
+			m.flushIngest()
+
+			got := [4]uint64{m.late.reordered.Load(), m.late.dropped.Load(), m.late.countedNow.Load(), m.late.backfilled.Load()}
+			if got != tt.counters {
+				t.Errorf("counters (reordered, dropped, counted now, back-filled) = %v, want %v", got, tt.counters)
+			}
+			m.sketchMu.Lock()
+			defer m.sketchMu.Unlock()
+			if n := m.sketch.Count("late"); n != tt.late {
+				t.Errorf("count of the late record = %d, want %d", n, tt.late)
+			}
+			if m.total.sum != tt.total {
+				t.Errorf("window total = %d, want %d", m.total.sum, tt.total)
+			}
+		})
+	}
+}
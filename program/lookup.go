@@ -0,0 +1,118 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/bubbles/cursor"
+	"github.com/charmbracelet/bubbles/textinput"
+	tui "github.com/charmbracelet/bubbletea"
+	"github.com/keilerkonzept/topk"
+	"github.com/keilerkonzept/topk/heap"
+)
+
+// lookup is the prompt for querying the sketch for any item, not only those
+// in the list. Only used by the update loop.
+type lookup struct {
+	input   textinput.Model
+	item    string // the last looked-up item, normalized; empty before the first
+	editing bool   // the prompt has focus and gets all keys
+}
+
+func newLookup() lookup {
+	in := textinput.New()
+	in.Prompt = "lookup: "
+	in.Placeholder = "item, e.g. 10.1.2.3"
+	in.Cursor.SetMode(cursor.CursorStatic)
+	return lookup{input: in}
+}
+
+// openLookup shows the lookup pane with the prompt focused.
+func (m *model) openLookup() tui.Cmd {
+	m.showLookup = true
+	m.lookup.editing = true
+	m.lookup.input.SetValue("")
+	return m.lookup.input.Focus()
+}
+
+// updateLookup handles a key while the prompt has focus: Enter looks the
+// item up, Esc leaves the prompt.
+func (m *model) updateLookup(msg tui.KeyMsg) tui.Cmd {
+	switch msg.Type {
+	case tui.KeyEsc:
+		m.lookup.editing = false
+		m.lookup.input.Blur()
+		if m.lookup.item == "" {
+			m.showLookup = false
+		}
+		return nil
+	case tui.KeyEnter:
+		item := strings.TrimSpace(m.lookup.input.Value())
+		if item == "" {
+			return nil
+		}
+		// Look the item up as it was counted.
+		m.lookup.item = m.normalizer.Load().apply(item)
+		m.lookup.editing = false
+		m.lookup.input.Blur()
+		return nil
+	}
+	var cmd tui.Cmd
+	m.lookup.input, cmd = m.lookup.input.Update(msg)
+	return cmd
+}
+
+// togglePinLookup pins or unpins the looked-up item.
+func (m *model) togglePinLookup() {
+	if err := m.pins.toggle(m.lookup.item); err != nil {
+		m.setNotice("pin: " + err.Error())
+	}
+}
+
+// lookupView renders the prompt and what the live sketch knows about the
+// looked-up item as h lines of width w.
+func (m *model) lookupView(w, h int) string {
+	m.lookup.input.Width = max(1, w-len(m.lookup.input.Prompt)-1)
+	header := m.lookup.input.View()
+	if !m.lookup.editing {
+		pinned := "m: pin"
+		if _, ok := m.pins.find(m.lookup.item); ok {
+			pinned = "m: unpin"
+		}
+		header = cutLine("lookup: "+m.lookup.item+"  (i: new lookup, "+pinned+", esc: close)", w)
+	}
+	lines := []string{header}
+	if m.lookup.item == "" {
+		return joinLines(lines, w, h)
+	}
+	d := m.lookupDetail(m.lookup.item)
+	var body []string
+	if !d.inHeap {
+		body = append(body,
+			"not tracked in the top-K heap: the sketch only keeps counts in the",
+			"buckets an item holds, and heavier items take them over, so an item",
+			"that was seen may still read as 0.",
+		)
+	}
+	body = append(body, "")
+	body = append(body, d.lines(w, h-len(lines)-len(body))...)
+	for _, line := range body {
+		lines = append(lines, cutLine(line, w))
+	}
+	return joinLines(lines, w, h)
+}
+
+// lookupDetail queries the live sketch for item.
+func (m *model) lookupDetail(item string) itemDetail {
+	m.sketchMu.Lock()
+	it := heap.Item{Item: item, Fingerprint: topk.Fingerprint(item), Count: m.sketch.Count(item)}
+	m.sketchMu.Unlock()
+	rank := 0
+	items, _ := m.rankedItems()
+	for i, ranked := range items {
+		if ranked.Item == item {
+			rank = i + 1
+			break
+		}
+	}
+	return m.itemDetail(it, rank, nil)
+}
@@ -9,6 +9,7 @@ import (
 	"log"
 	"math"
 	"os"
+	"slices"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -36,6 +37,11 @@ type Config struct {
 	TickSize     time.Duration
 	WindowSize   time.Duration
 
+	// sketch sizing
+	MemoryBudget  byteSize
+	TargetError   float64
+	HistoryLength int
+
 	// render
 	PlotFPS       int
 	ItemsFPS      int
@@ -43,6 +49,11 @@ type Config struct {
 	TrackSelected bool
 	LogScale      bool
 	ViewSplit     int
+	Sort          string
+	Chart         string
+	TrendTicks    int
+	RiseThreshold float64
+	ScrubHistory  time.Duration
 
 	// input
 	InputPath       string
@@ -51,19 +62,44 @@ type Config struct {
 	Replay          bool
 	ReplaySpeed     float64
 	ReplayMaxSleep  time.Duration
+	ReplayJump      time.Duration
+	ReplayFrom      string
+	ReplayUntil     string
+	IdleAdvance     time.Duration
+	AllowedLateness time.Duration
+	LatePolicy      string
+	NewSession      bool
 	AccessLog       bool
 	JSON            bool
 	TimestampLayout string
+	Normalize       stringList
+	NormalizeFile   string
+	ParseWorkers    int
 
 	// experiment
 	SearchEnabled bool
 	FullRefresh   time.Duration
 	PartialSize   int
+	IngestBatch   int
 
 	StatsEnabled bool
 	StatsWindow  int
+	Verify       bool
+	Journal      string
+
+	// alerts
+	Alerts        stringList
+	AlertSinks    stringList
+	AlertCooldown time.Duration
 
 	AltScreen bool
+	Mouse     string
+
+	// config file
+	ConfigPath string
+	Profile    string
+
+	explicit map[string]bool // flags set on the command line or in the -config file
 }
 
 var config = Config{
@@ -79,6 +115,11 @@ var config = Config{
 	PlotFPS:       20,
 	ItemsFPS:      1,
 	ItemCountsFPS: 5,
+	Sort:          sortByCount,
+	Chart:         chartLines,
+	TrendTicks:    5,
+	RiseThreshold: 3,
+	ScrubHistory:  time.Hour,
 
 	InputPath:       "",
 	MaxLines:        0,
@@ -86,20 +127,31 @@ var config = Config{
 	Replay:          false,
 	ReplaySpeed:     1.0,
 	ReplayMaxSleep:  0,
+	ReplayJump:      5 * time.Minute,
+	LatePolicy:      latePolicyCountNow,
 	AccessLog:       false,
 	JSON:            false,
 	TimestampLayout: time.RFC3339,
+	ParseWorkers:    1,
 
 	SearchEnabled: true,
 	FullRefresh:   2 * time.Second,
 	PartialSize:   0,
+	IngestBatch:   64,
 
 	StatsEnabled: true,
 	StatsWindow:  256,
 
+	AlertCooldown: time.Minute,
+
 	AltScreen: true,
+	Mouse:     mouseCell,
 }
 
+// defaultConfig holds the built-in defaults that flags and config files are
+// applied on top of.
+var defaultConfig = config
+
 var (
 	selectedColor = styles.AdaptiveColor{Light: "0", Dark: "9"}
 	borderColor   = styles.AdaptiveColor{Light: "#555", Dark: "#555"}
@@ -112,129 +164,300 @@ var (
 )
 
 func main() {
-	flag.IntVar(&config.K, "k", config.K, "Track the top K items")
-	flag.IntVar(&config.Width, "width", config.Width, "Sketch width")
-	flag.IntVar(&config.Depth, "depth", config.Depth, "Sketch depth")
-	flag.DurationVar(&config.WindowSize, "window", config.WindowSize, "Window size")
-	flag.DurationVar(&config.TickSize, "tick", config.TickSize, "Sliding window tick size (time bucket precision)")
-	flag.Float64Var(&config.Decay, "decay", config.Decay, "Counter decay probability on collisions")
-	flag.IntVar(&config.DecayLUTSize, "decay-lut-size", config.DecayLUTSize, "Sketch decay look-up table size")
-	flag.IntVar(&config.PlotFPS, "plot-fps", config.PlotFPS, "Plot refresh rate (frames per second)")
-	flag.IntVar(&config.ItemsFPS, "items-fps", config.ItemsFPS, "Item refresh rate (frames per second)")
-	flag.IntVar(&config.ItemCountsFPS, "item-counts-fps", config.ItemCountsFPS, "Item counts refresh rate (frames per second; 0 disables)")
-	flag.StringVar(&config.InputPath, "in", config.InputPath, "Read input from this file instead of stdin")
-	flag.IntVar(&config.MaxLines, "max-lines", config.MaxLines, "Stop after reading this many records (0 = unlimited)")
-	flag.DurationVar(&config.Pace, "pace", config.Pace, "Sleep between input records (e.g. 5ms, 50ms)")
-	flag.BoolVar(&config.Replay, "replay", config.Replay, "Replay timestamped input in (scaled) real time (requires -access-log or -json with timestamps)")
-	flag.Float64Var(&config.ReplaySpeed, "replay-speed", config.ReplaySpeed, "Replay speed factor (1=real-time, 2=2x faster, 0.5=2x slower)")
-	flag.DurationVar(&config.ReplayMaxSleep, "replay-max-sleep", config.ReplayMaxSleep, "Cap per-record replay sleep (0 = no cap)")
-	flag.BoolVar(&config.AccessLog, "access-log", config.AccessLog, "Parse access log lines into {item,timestamp} records (item=client IP)")
-	flag.BoolVar(&config.JSON, "json", config.JSON, "Read JSON records {item,[count],[timestamp]} instead of text lines")
-	flag.BoolVar(&config.TrackSelected, "track-selected", config.TrackSelected, "Keep the selected item focused")
-	flag.BoolVar(&config.LogScale, "log-scale", config.LogScale, "Use a logarithmic Y axis scale (default: linear)")
-	flag.StringVar(&config.TimestampLayout, "json-timestamp-layout", config.TimestampLayout, "Layout for string values of the timestamp field")
-	flag.IntVar(&config.ViewSplit, "view-split", config.ViewSplit, "Split the view at this % of the total screen width [20,80]")
-
-	flag.BoolVar(&config.SearchEnabled, "search", config.SearchEnabled, "Enable search/filtering in the leaderboard list")
-	flag.DurationVar(&config.FullRefresh, "full-refresh", config.FullRefresh, "How often to do a full Top-K refresh (0 = always)")
-	flag.IntVar(&config.PartialSize, "partial-size", config.PartialSize, "How many items to partially refresh/sort per tick (0 = auto budget, about half of K)")
-	flag.BoolVar(&config.StatsEnabled, "stats", config.StatsEnabled, "Show runtime performance stats")
-	flag.IntVar(&config.StatsWindow, "stats-window", config.StatsWindow, "Number of recent samples kept per metric")
-	flag.BoolVar(&config.AltScreen, "alt-screen", config.AltScreen, "Use the terminal alternate screen buffer (recommended inside IDE terminals)")
-
-	flag.Parse()
-
-	if err := validateAndNormalizeConfig(); err != nil {
-		log.Fatal(err)
+	if len(os.Args) > 1 && os.Args[1] == "bench" {
+		if err := runBench(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
 	}
 
-	if config.ReplaySpeed <= 0 {
-		config.ReplaySpeed = 1.0
-	}
-	config.ViewSplit = max(20, config.ViewSplit)
-	config.ViewSplit = min(80, config.ViewSplit)
-	if config.StatsWindow < 16 {
-		config.StatsWindow = 16
+	c, err := loadConfig(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
 	}
+	config = c
 
-	sketch := sliding.New(config.K,
-		int(config.WindowSize/config.TickSize),
-		sliding.WithWidth(config.Width),
-		sliding.WithDepth(config.Depth),
-		sliding.WithDecay(float32(config.Decay)),
-		sliding.WithDecayLUTSize(config.DecayLUTSize),
-	)
+	norm, err := newNormalizer(config.Normalize)
+	if err != nil {
+		log.Fatal(err)
+	}
 
+	sketch := newSketch(&config)
 	m := newModel(sketch)
+	m.normalizer.Store(norm)
+	if m.journal, err = newJournal(config.Journal); err != nil {
+		log.Fatal(err)
+	}
+	if m.alerts, err = newAlerter(config.Alerts, config.AlertSinks, config.AlertCooldown, nil, m.alertError); err != nil {
+		log.Fatal(err)
+	}
 	opts := []tui.ProgramOption{tui.WithInputTTY()}
 	if config.AltScreen {
 		opts = append(opts, tui.WithAltScreen())
 	}
-	if _, err := tui.NewProgram(m, opts...).Run(); err != nil {
+	if opt, ok := mouseOption(); ok {
+		opts = append(opts, opt)
+	}
+	_, err = tui.NewProgram(m, opts...).Run()
+	m.alerts.close()
+	if cerr := m.journal.close(); cerr != nil {
+		log.Print(cerr)
+	}
+	if err != nil {
 		log.Fatal(err)
 	}
+	if m.verifier != nil {
+		fmt.Println(m.verifier.report())
+	}
+}
+
+func newSketch(c *Config) *sliding.Sketch {
+	opts := []sliding.Option{
+		sliding.WithWidth(c.Width),
+		sliding.WithDepth(c.Depth),
+		sliding.WithDecay(float32(c.Decay)),
+		sliding.WithDecayLUTSize(c.DecayLUTSize),
+	}
+	if c.HistoryLength > 0 {
+		opts = append(opts, sliding.WithBucketHistoryLength(c.HistoryLength))
+	}
+	return sliding.New(c.K, int(c.WindowSize/c.TickSize), opts...)
+}
+
+// registerFlags defines the command-line flags on fs, using c's current
+// values as defaults.
+func registerFlags(fs *flag.FlagSet, c *Config) {
+	fs.IntVar(&c.K, "k", c.K, "Track the top K items")
+	fs.IntVar(&c.Width, "width", c.Width, "Sketch width")
+	fs.IntVar(&c.Depth, "depth", c.Depth, "Sketch depth")
+	fs.DurationVar(&c.WindowSize, "window", c.WindowSize, "Window size")
+	fs.DurationVar(&c.TickSize, "tick", c.TickSize, "Sliding window tick size (time bucket precision)")
+	fs.Float64Var(&c.Decay, "decay", c.Decay, "Counter decay probability on collisions")
+	fs.IntVar(&c.DecayLUTSize, "decay-lut-size", c.DecayLUTSize, "Sketch decay look-up table size")
+	fs.Var(&c.MemoryBudget, "memory-budget", "Derive -width, -depth and -bucket-history from this sketch memory budget (e.g. 64MB)")
+	fs.Float64Var(&c.TargetError, "target-error", c.TargetError, "Derive -width and -depth from this error bound, as a fraction of the window total (e.g. 0.001)")
+	fs.IntVar(&c.HistoryLength, "bucket-history", c.HistoryLength, "Aged counters per sketch bucket (0 = one per tick)")
+	fs.IntVar(&c.PlotFPS, "plot-fps", c.PlotFPS, "Plot refresh rate (frames per second)")
+	fs.IntVar(&c.ItemsFPS, "items-fps", c.ItemsFPS, "Item refresh rate (frames per second)")
+	fs.IntVar(&c.ItemCountsFPS, "item-counts-fps", c.ItemCountsFPS, "Item counts refresh rate (frames per second; 0 disables)")
+	fs.StringVar(&c.InputPath, "in", c.InputPath, "Read input from this file instead of stdin")
+	fs.IntVar(&c.MaxLines, "max-lines", c.MaxLines, "Stop after reading this many records (0 = unlimited)")
+	fs.DurationVar(&c.Pace, "pace", c.Pace, "Sleep between input records (e.g. 5ms, 50ms)")
+	fs.BoolVar(&c.Replay, "replay", c.Replay, "Replay timestamped input in (scaled) real time (requires -access-log or -json with timestamps)")
+	fs.Float64Var(&c.ReplaySpeed, "replay-speed", c.ReplaySpeed, "Replay speed factor (1=real-time, 2=2x faster, 0.5=2x slower)")
+	fs.DurationVar(&c.ReplayMaxSleep, "replay-max-sleep", c.ReplayMaxSleep, "Cap per-record replay sleep (0 = no cap)")
+	fs.DurationVar(&c.ReplayJump, "replay-jump", c.ReplayJump, "Event time skipped by the jump key")
+	fs.StringVar(&c.ReplayFrom, "replay-from", c.ReplayFrom, "Start at this event time, warming up with the window before it (e.g. 14:32 or 2019-01-22 14:32)")
+	fs.StringVar(&c.ReplayUntil, "replay-until", c.ReplayUntil, "Stop reading at this event time")
+	fs.DurationVar(&c.IdleAdvance, "idle-advance", c.IdleAdvance, "When timestamped input has been quiet for this long, advance event time with the wall clock (0 = off; ignored with -replay)")
+	fs.DurationVar(&c.AllowedLateness, "allowed-lateness", c.AllowedLateness, "Hold timestamped records this long in event time to put them back in order (0 = off)")
+	fs.StringVar(&c.LatePolicy, "late-policy", c.LatePolicy, "Records older than the current tick: drop, count-now or count-in-bucket")
+	fs.BoolVar(&c.NewSession, "new-session-on-backwards", c.NewSession, "Restart the window when event time jumps back by more than the window (default: treat as late records)")
+	fs.BoolVar(&c.AccessLog, "access-log", c.AccessLog, "Parse access log lines into {item,timestamp} records (item=client IP)")
+	fs.BoolVar(&c.JSON, "json", c.JSON, "Read JSON records {item,[count],[timestamp]} instead of text lines")
+	fs.BoolVar(&c.TrackSelected, "track-selected", c.TrackSelected, "Keep the selected item focused")
+	fs.BoolVar(&c.LogScale, "log-scale", c.LogScale, "Use a logarithmic Y axis scale (default: linear)")
+	fs.StringVar(&c.TimestampLayout, "json-timestamp-layout", c.TimestampLayout, "Layout for string values of the timestamp field")
+	fs.IntVar(&c.ViewSplit, "view-split", c.ViewSplit, "Split the view at this % of the total screen width [20,80]")
+	fs.StringVar(&c.Sort, "sort", c.Sort, "Leaderboard order: count, or rise (trend score against the item's own baseline)")
+	fs.StringVar(&c.Chart, "chart", c.Chart, "Chart shown next to the list: lines, stacked, heatmap or bars (cycle with c)")
+	fs.IntVar(&c.TrendTicks, "trend-ticks", c.TrendTicks, "Recent ticks compared against the rest of the window for trend scores")
+	fs.Float64Var(&c.RiseThreshold, "rise-threshold", c.RiseThreshold, "Trend score (z-score) at which an item gets a rising badge")
+	fs.DurationVar(&c.ScrubHistory, "scrub-history", c.ScrubHistory, "Event time of leaderboard snapshots kept for scrubbing ([, then ← and →) (0 = off)")
+	fs.Var(&c.Normalize, "normalize", "Item transform applied before counting, repeatable (ipv4-prefix=N, ipv6-prefix=N, path-template, strip-query, lower, regex=RE=>REPL)")
+	fs.IntVar(&c.ParseWorkers, "parse-workers", c.ParseWorkers, "Parse text and access-log input on this many goroutines (0 = one per CPU, 1 = sequential)")
+	fs.StringVar(&c.NormalizeFile, "normalize-file", c.NormalizeFile, "Read -normalize transforms from this file (one per line, applied after flag transforms)")
+
+	fs.BoolVar(&c.SearchEnabled, "search", c.SearchEnabled, "Enable search/filtering in the leaderboard list")
+	fs.DurationVar(&c.FullRefresh, "full-refresh", c.FullRefresh, "How often to do a full Top-K refresh (0 = always)")
+	fs.IntVar(&c.PartialSize, "partial-size", c.PartialSize, "How many items to partially refresh/sort per tick (0 = auto budget, about half of K)")
+	fs.IntVar(&c.IngestBatch, "ingest-batch", c.IngestBatch, "Records buffered per sketch lock acquisition (1 = lock per record)")
+	fs.BoolVar(&c.StatsEnabled, "stats", c.StatsEnabled, "Show runtime performance stats")
+	fs.IntVar(&c.StatsWindow, "stats-window", c.StatsWindow, "Number of recent samples kept per metric")
+	fs.BoolVar(&c.Verify, "verify", c.Verify, "Keep exact window counts alongside the sketch and report top-K accuracy")
+	fs.StringVar(&c.Journal, "journal", c.Journal, "Append leaderboard changes (enter, exit, rank) to this file as NDJSON")
+	fs.Var(&c.Alerts, "alert", "Alert rule checked on every leaderboard refresh, repeatable (count>N, rise>Z, enter-top=N, top-changed)")
+	fs.Var(&c.AlertSinks, "alert-sink", "Where fired alerts go, repeatable (stderr, file=PATH, exec=COMMAND, webhook=URL)")
+	fs.DurationVar(&c.AlertCooldown, "alert-cooldown", c.AlertCooldown, "Don't fire an alert again for the same rule and item within this time")
+	fs.BoolVar(&c.AltScreen, "alt-screen", c.AltScreen, "Use the terminal alternate screen buffer (recommended inside IDE terminals)")
+	fs.StringVar(&c.Mouse, "mouse", c.Mouse, "Mouse input: cell (clicks, wheel and drags), all (also hovering, not supported by every terminal) or off")
+
+	fs.StringVar(&c.ConfigPath, "config", c.ConfigPath, "Read settings from this YAML, TOML or JSON file (flags take precedence)")
+	fs.StringVar(&c.Profile, "profile", c.Profile, "Apply this named profile from the -config file")
+}
+
+// loadConfig parses args with fs, applies the -config file, and validates the
+// result. It starts from the built-in defaults, so it can be called again to
+// reload the configuration.
+func loadConfig(fs *flag.FlagSet, args []string) (Config, error) {
+	c := defaultConfig
+	registerFlags(fs, &c)
+	if err := fs.Parse(args); err != nil {
+		return c, err
+	}
+	sources, err := applyConfigFile(fs, c.ConfigPath, c.Profile)
+	if err != nil {
+		return c, err
+	}
+	c.explicit = make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { c.explicit[f.Name] = true })
+	if err := validateAndNormalizeConfig(&c); err != nil {
+		return c, withConfigSource(err, sources)
+	}
+	return c, nil
 }
 
-func validateAndNormalizeConfig() error {
-	if config.K < 1 {
-		return fmt.Errorf("-k must be >= 1")
+func validateAndNormalizeConfig(c *Config) error {
+	if c.K < 1 {
+		return flagErrorf("k", "must be >= 1")
+	}
+	if c.Width < 1 {
+		return flagErrorf("width", "must be >= 1")
+	}
+	if c.Depth < 1 {
+		return flagErrorf("depth", "must be >= 1")
+	}
+	if c.Decay < 0 || c.Decay > 1 {
+		return flagErrorf("decay", "must be in [0,1]")
+	}
+	if c.DecayLUTSize < 1 {
+		return flagErrorf("decay-lut-size", "must be >= 1")
+	}
+	if c.TickSize <= 0 {
+		return flagErrorf("tick", "must be > 0")
+	}
+	if c.WindowSize <= 0 {
+		return flagErrorf("window", "must be > 0")
 	}
-	if config.Width < 1 {
-		return fmt.Errorf("-width must be >= 1")
+	if c.WindowSize < c.TickSize {
+		return flagErrorf("window", "must be >= -tick")
 	}
-	if config.Depth < 1 {
-		return fmt.Errorf("-depth must be >= 1")
+	if c.WindowSize%c.TickSize != 0 {
+		return flagErrorf("window", "must be a multiple of -tick (got window=%s tick=%s)", c.WindowSize, c.TickSize)
 	}
-	if config.Decay < 0 || config.Decay > 1 {
-		return fmt.Errorf("-decay must be in [0,1]")
+	if c.TargetError < 0 || c.TargetError >= 1 {
+		return flagErrorf("target-error", "must be in [0,1)")
 	}
-	if config.DecayLUTSize < 1 {
-		return fmt.Errorf("-decay-lut-size must be >= 1")
+	if c.HistoryLength < 0 {
+		return flagErrorf("bucket-history", "must be >= 0")
 	}
-	if config.TickSize <= 0 {
-		return fmt.Errorf("-tick must be > 0")
+	if c.PlotFPS < 1 {
+		return flagErrorf("plot-fps", "must be >= 1")
 	}
-	if config.WindowSize <= 0 {
-		return fmt.Errorf("-window must be > 0")
+	if c.ItemsFPS < 1 {
+		return flagErrorf("items-fps", "must be >= 1")
 	}
-	if config.WindowSize < config.TickSize {
-		return fmt.Errorf("-window must be >= -tick")
+	if c.ItemCountsFPS < 0 {
+		return flagErrorf("item-counts-fps", "must be >= 0")
 	}
-	if config.WindowSize%config.TickSize != 0 {
-		return fmt.Errorf("-window must be a multiple of -tick (got window=%s tick=%s)", config.WindowSize, config.TickSize)
+	if c.MaxLines < 0 {
+		return flagErrorf("max-lines", "must be >= 0")
 	}
-	if config.PlotFPS < 1 {
-		return fmt.Errorf("-plot-fps must be >= 1")
+	if c.Pace < 0 {
+		return flagErrorf("pace", "must be >= 0")
 	}
-	if config.ItemsFPS < 1 {
-		return fmt.Errorf("-items-fps must be >= 1")
+	if c.ParseWorkers < 0 {
+		return flagErrorf("parse-workers", "must be >= 0")
 	}
-	if config.ItemCountsFPS < 0 {
-		return fmt.Errorf("-item-counts-fps must be >= 0")
+	if c.ReplaySpeed <= 0 {
+		return flagErrorf("replay-speed", "must be > 0")
+	}
+	if c.ReplayMaxSleep < 0 {
+		return flagErrorf("replay-max-sleep", "must be >= 0")
+	}
+	if c.ReplayJump <= 0 {
+		return flagErrorf("replay-jump", "must be > 0")
+	}
+	if c.Replay && !(c.AccessLog || c.JSON) {
+		return flagErrorf("replay", "requires -access-log or -json")
+	}
+	for _, bound := range []struct{ name, value string }{
+		{"replay-from", c.ReplayFrom},
+		{"replay-until", c.ReplayUntil},
+	} {
+		if bound.value == "" {
+			continue
+		}
+		if !(c.AccessLog || c.JSON) {
+			return flagErrorf(bound.name, "requires -access-log or -json")
+		}
+		if _, err := parseTimeBound(bound.value, time.Unix(0, 0).UTC()); err != nil {
+			return flagErrorf(bound.name, "%v", err)
+		}
 	}
-	if config.MaxLines < 0 {
-		return fmt.Errorf("-max-lines must be >= 0")
+	if c.Sort != sortByCount && c.Sort != sortByRise {
+		return flagErrorf("sort", "must be %s or %s", sortByCount, sortByRise)
 	}
-	if config.Pace < 0 {
-		return fmt.Errorf("-pace must be >= 0")
+	if c.Mouse != mouseCell && c.Mouse != mouseAll && c.Mouse != mouseOff {
+		return flagErrorf("mouse", "must be %s, %s or %s", mouseCell, mouseAll, mouseOff)
+	}
+	if !slices.Contains(chartModes, c.Chart) {
+		return flagErrorf("chart", "must be one of %s", strings.Join(chartModes, ", "))
+	}
+	if c.TrendTicks < 1 {
+		return flagErrorf("trend-ticks", "must be >= 1")
+	}
+	if c.ScrubHistory < 0 {
+		return flagErrorf("scrub-history", "must be >= 0")
+	}
+	if c.IdleAdvance < 0 {
+		return flagErrorf("idle-advance", "must be >= 0")
+	}
+	if c.AllowedLateness < 0 {
+		return flagErrorf("allowed-lateness", "must be >= 0")
+	}
+	switch c.LatePolicy {
+	case latePolicyDrop, latePolicyCountNow, latePolicyCountInBucket:
+	default:
+		return flagErrorf("late-policy", "must be %s, %s or %s", latePolicyDrop, latePolicyCountNow, latePolicyCountInBucket)
+	}
+	if c.AccessLog && c.JSON {
+		return flagErrorf("json", "cannot be combined with -access-log")
+	}
+	if c.FullRefresh < 0 {
+		return flagErrorf("full-refresh", "must be >= 0")
+	}
+	if c.PartialSize < 0 {
+		return flagErrorf("partial-size", "must be >= 0")
+	}
+	if c.IngestBatch < 1 {
+		return flagErrorf("ingest-batch", "must be >= 1")
+	}
+	if c.NormalizeFile != "" {
+		specs, err := readNormalizeFile(c.NormalizeFile)
+		if err != nil {
+			return flagErrorf("normalize-file", "could not be read: %v", err)
+		}
+		c.Normalize = append(c.Normalize, specs...)
+	}
+	if _, err := newNormalizer(c.Normalize); err != nil {
+		return err
 	}
-	if config.ReplaySpeed <= 0 {
-		return fmt.Errorf("-replay-speed must be > 0")
+	// After the normalizer, which the input sample for sizing goes through.
+	if err := sizeSketch(c); err != nil {
+		return err
 	}
-	if config.ReplayMaxSleep < 0 {
-		return fmt.Errorf("-replay-max-sleep must be >= 0")
+	for _, spec := range c.Alerts {
+		if _, err := parseAlertRule(spec); err != nil {
+			return err
+		}
 	}
-	if config.Replay && !(config.AccessLog || config.JSON) {
-		return fmt.Errorf("-replay requires -access-log or -json")
+	for _, spec := range c.AlertSinks {
+		if _, _, err := parseAlertSink(spec); err != nil {
+			return err
+		}
 	}
-	if config.AccessLog && config.JSON {
-		return fmt.Errorf("choose only one: -access-log or -json")
+	if len(c.AlertSinks) > 0 && len(c.Alerts) == 0 {
+		return flagErrorf("alert-sink", "requires -alert")
 	}
-	if config.FullRefresh < 0 {
-		return fmt.Errorf("-full-refresh must be >= 0")
+	if c.AlertCooldown < 0 {
+		return flagErrorf("alert-cooldown", "must be >= 0")
 	}
-	if config.PartialSize < 0 {
-		return fmt.Errorf("-partial-size must be >= 0")
+
+	c.ViewSplit = max(20, c.ViewSplit)
+	c.ViewSplit = min(80, c.ViewSplit)
+	if c.StatsWindow < 16 {
+		c.StatsWindow = 16
 	}
 	return nil
 }
@@ -244,9 +467,11 @@ type model struct {
 	leftPaneWidth  int
 	rightPaneWidth int
 
-	track    bool
-	logScale atomic.Bool
-	err      error
+	track     bool
+	logScale  atomic.Bool
+	sortBy    string // -sort, toggled by o; only used by the update loop
+	chartMode string // -chart, cycled by c; only used by the update loop
+	err       error
 
 	paused    bool
 	pauseMu   sync.Mutex
@@ -257,18 +482,52 @@ type model struct {
 	listDelegate *list.DefaultDelegate
 	help         help.Model
 	plot         *plot.Canvas
+	plotW, plotH int
+	showJournal  bool // the journal pane replaces the plot
+	showDetail   bool // the detail pane replaces the plot
+	showLookup   bool // the lookup pane replaces the plot
+	lookup       lookup
+	hover        hover
 
 	sketch         *sliding.Sketch
 	sketchMu       sync.Mutex
+	total          *windowTotal // guarded by sketchMu
 	plotData       [][]float64
+	plotFit        [][]float64 // plotData resampled to the canvas width
 	plotLineColors []plot.Color
+	plotBounds     plotBounds // guarded by mu
+	pins           pins
+	chart          chartData
 	listItems      []heap.Item
+	trends         map[string]trend
 	latestTick     time.Time
+	startTick      time.Time // first tick since startup or the last reset
 
 	timestampsFromData atomic.Bool
 
-	ranker  *IncrementalRanker
-	metrics *latencyMetrics
+	ingestBuf  *ingestBuffer
+	late       lateCounters
+	clock      clockEvents // guarded by mu
+	markerRow  bool        // layout reserves a row for clock markers
+	replay     *replayControl
+	ranker     *IncrementalRanker
+	metrics    *latencyMetrics
+	verifier   *verifier
+	alerts     *alerter           // only used by the update loop
+	journal    *journal           // only used by the update loop
+	history    leaderboardHistory // only used by the update loop
+	scrubMode  bool               // ←/→ step through history; only used by the update loop
+	normalizer atomic.Pointer[normalizer]
+	timeRange  *timeRange // only used by the reader goroutine
+	eventClock atomic.Pointer[eventClock]
+
+	reloadSignals     chan os.Signal
+	configModTime     time.Time
+	notice            string    // guarded by mu
+	noticeUntil       time.Time // guarded by mu; the notice is hidden after this
+	noticeRow         bool      // layout reserves a row for the notice
+	statsRows         int       // rows the layout reserves for STATS
+	itemCountsTicking bool
 
 	done chan struct{}
 	mu   sync.Mutex
@@ -300,9 +559,8 @@ func newModel(sketch *sliding.Sketch) *model {
 	l.SetShowStatusBar(false)
 
 	p := plot.NewCanvas(defaultWidth, defaultHeight)
-	p.NumDataPoints = sketch.BucketHistoryLength
 	p.ShowAxis = false
-	p.LineColors = make([]plot.Color, config.K+1)
+	p.LineColors = make([]plot.Color, config.K+1+len(pinPalette))
 
 	help := help.New()
 
@@ -312,17 +570,27 @@ func newModel(sketch *sliding.Sketch) *model {
 
 	m := &model{
 		track:          config.TrackSelected,
+		sortBy:         config.Sort,
+		chartMode:      config.Chart,
 		sketch:         sketch,
 		help:           help,
 		list:           l,
 		listDelegate:   &d,
 		plot:           &p,
-		plotData:       make([][]float64, config.K+1),
-		plotLineColors: make([]plot.Color, config.K+1),
+		plotData:       make([][]float64, config.K+1+len(pinPalette)),
+		plotLineColors: make([]plot.Color, config.K+1+len(pinPalette)),
 		ranker:         ranker,
 		metrics:        metrics,
+		ingestBuf:      newIngestBuffer(config.IngestBatch),
+		total:          newWindowTotal(int(config.WindowSize / config.TickSize)),
+		replay:         newReplayControl(config.ReplaySpeed),
+		journal:        newMemoryJournal(),
+		lookup:         newLookup(),
 		done:           make(chan struct{}),
 	}
+	if config.Verify {
+		m.verifier = newVerifier(int(config.WindowSize / config.TickSize))
+	}
 	m.leftPaneWidth, m.rightPaneWidth = computePaneWidths(defaultWidth, config.ViewSplit)
 	m.pauseCond = sync.NewCond(&m.pauseMu)
 	// Default: advance time in real-time (stdin has no timestamps).
@@ -362,29 +630,34 @@ func (m *model) readAndCountInput() tui.Cmd {
 			return nil
 		}
 		defer func() { _ = r.Close() }()
-		switch {
-		case config.AccessLog:
-			// Stay in realtime-tick mode until we see a valid timestamp.
-			m.timestampsFromData.Store(false)
-			if err := m.readAccessLogItems(r); err != nil {
-				return errMsg{err}
-			}
-		case config.JSON:
-			// Enable data timestamps only after we see a valid timestamp.
-			m.timestampsFromData.Store(false)
-			if err := m.readJSONItems(r); err != nil {
-				return errMsg{err}
-			}
-		default:
-			m.timestampsFromData.Store(false)
-			if err := m.readTextItems(r); err != nil {
-				return errMsg{err}
-			}
+		defer m.flushIngest()
+		if err := m.readInput(r); err != nil {
+			return errMsg{err}
 		}
 		return nil
 	}
 }
 
+// readInput counts every record of r with the reader for the input format.
+func (m *model) readInput(r io.Reader) error {
+	// Stay in realtime-tick mode until we see a valid timestamp.
+	m.timestampsFromData.Store(false)
+	m.timeRange = newTimeRange()
+	if err := m.seekInput(r); err != nil {
+		return err
+	}
+	switch {
+	case !config.JSON && parseWorkers() > 1:
+		return m.readLinesParallel(r, parseWorkers(), config.AccessLog)
+	case config.AccessLog:
+		return m.readAccessLogItems(r)
+	case config.JSON:
+		return m.readJSONItems(r)
+	default:
+		return m.readTextItems(r)
+	}
+}
+
 func (m *model) openInput() (io.ReadCloser, bool, error) {
 	if config.InputPath != "" {
 		f, err := os.Open(config.InputPath)
@@ -411,12 +684,7 @@ func (m *model) readTextItems(r io.Reader) error {
 		if config.MaxLines > 0 && n >= config.MaxLines {
 			return nil
 		}
-		item := scanner.Text()
-		now := time.Now()
-		m.sketchMu.Lock()
-		m.sketch.Incr(item)
-		m.sketchMu.Unlock()
-		m.metrics.observeIngest(now)
+		m.ingest(m.normalizer.Load().apply(unsafeString(scanner.Bytes())), 1)
 		n++
 		if config.Pace > 0 {
 			time.Sleep(config.Pace)
@@ -430,16 +698,11 @@ func (m *model) readTextItems(r io.Reader) error {
 
 func (m *model) readJSONItems(r io.Reader) error {
 	dec := json.NewDecoder(bufio.NewReader(r))
-	var last time.Time
-	var prevEvent time.Time
-	useEventTime := false
+	clock := m.newEventClock()
+	defer m.drainEvents(clock)
 	n := 0
 	for {
-		item := struct {
-			Item      string `json:"item"`
-			Count     int    `json:"count"`
-			Timestamp any    `json:"timestamp"`
-		}{}
+		item := jsonRecord{}
 
 		if m.isDone() {
 			return nil
@@ -456,57 +719,31 @@ func (m *model) readJSONItems(r io.Reader) error {
 			return err
 		}
 
-		eventTime := time.Time{}
-		if item.Timestamp != nil {
-			switch timestamp := item.Timestamp.(type) {
-			case int:
-				eventTime = time.Unix(int64(timestamp), 0)
-			case float64:
-				eventTime = time.Unix(int64(timestamp), 0)
-			case string:
-				eventTime, _ = time.Parse(config.TimestampLayout, timestamp)
-			}
-
-		}
+		eventTime := item.eventTime(config.TimestampLayout)
 
 		if config.Replay && eventTime.IsZero() {
 			return fmt.Errorf("replay enabled but JSON record has missing/invalid timestamp")
 		}
 
-		if !eventTime.IsZero() {
-			if !useEventTime {
-				useEventTime = true
-				m.timestampsFromData.Store(true)
-			}
-			if config.Replay && !prevEvent.IsZero() {
-				sleep := time.Duration(float64(eventTime.Sub(prevEvent)) / config.ReplaySpeed)
-				if sleep > 0 {
-					if config.ReplayMaxSleep > 0 && sleep > config.ReplayMaxSleep {
-						sleep = config.ReplayMaxSleep
-					}
-					time.Sleep(sleep)
-				}
-			}
-			prevEvent = eventTime
-			m.metrics.observeEventTime(eventTime)
-			last = m.doSketchTicks(eventTime, last)
-			m.mu.Lock()
-			m.latestTick = last
-			m.mu.Unlock()
-		} else if !useEventTime {
-			// Stay in realtime-tick mode until we see a valid timestamp.
-			m.timestampsFromData.Store(false)
+		if skip, stop := m.inTimeRange(eventTime); stop {
+			return nil
+		} else if skip {
+			continue
 		}
 
 		inc := item.Count
 		if inc < 1 {
 			inc = 1
 		}
-		now := time.Now()
-		m.sketchMu.Lock()
-		m.sketch.Add(item.Item, uint32(inc))
-		m.sketchMu.Unlock()
-		m.metrics.observeIngest(now)
+		if !eventTime.IsZero() {
+			m.countEvent(clock, eventTime, item.Item, uint32(inc))
+		} else {
+			if !clock.started {
+				// Stay in realtime-tick mode until we see a valid timestamp.
+				m.timestampsFromData.Store(false)
+			}
+			m.ingest(m.normalizer.Load().apply(item.Item), uint32(inc))
+		}
 
 		n++
 		if !config.Replay && config.Pace > 0 {
@@ -515,12 +752,61 @@ func (m *model) readJSONItems(r io.Reader) error {
 	}
 }
 
+type jsonRecord struct {
+	Item      string `json:"item"`
+	Count     int    `json:"count"`
+	Timestamp any    `json:"timestamp"`
+}
+
+// eventTime returns the record's timestamp, with string timestamps in layout,
+// or the zero time if it is missing or invalid.
+func (r *jsonRecord) eventTime(layout string) time.Time {
+	switch timestamp := r.Timestamp.(type) {
+	case int:
+		return time.Unix(int64(timestamp), 0)
+	case float64:
+		return time.Unix(int64(timestamp), 0)
+	case string:
+		t, _ := time.Parse(layout, timestamp)
+		return t
+	}
+	return time.Time{}
+}
+
+// parseAccessLogLine splits a common/combined log format line into the client
+// IP and the raw timestamp between the brackets.
+func parseAccessLogLine(line string) (ip, ts string, ok bool) {
+	ip, ts, ok = strings.Cut(line, " - - [")
+	if !ok {
+		return "", "", false
+	}
+	ts, _, ok = strings.Cut(ts, "]")
+	return ip, ts, ok
+}
+
+// timestampCache remembers the last parsed access-log timestamp. Log lines
+// arrive in bursts that share a timestamp down to the second, so most lines
+// skip time.Parse entirely.
+type timestampCache struct {
+	raw string
+	t   time.Time
+	err error
+}
+
+func (c *timestampCache) parse(ts string) (time.Time, error) {
+	if c.raw == "" || ts != c.raw {
+		c.raw = strings.Clone(ts)
+		c.t, c.err = time.Parse(config.TimestampLayout, ts)
+	}
+	return c.t, c.err
+}
+
 func (m *model) readAccessLogItems(r io.Reader) error {
 	scanner := bufio.NewScanner(r)
 	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
-	var last time.Time
-	var prevEvent time.Time
-	useEventTime := false
+	clock := m.newEventClock()
+	defer m.drainEvents(clock)
+	var timestamps timestampCache
 	n := 0
 	for scanner.Scan() {
 		if m.isDone() {
@@ -530,47 +816,27 @@ func (m *model) readAccessLogItems(r io.Reader) error {
 		if config.MaxLines > 0 && n >= config.MaxLines {
 			return nil
 		}
-		line := scanner.Text()
-		ip, ts, ok := strings.Cut(line, " - - [")
-		if !ok {
-			continue
-		}
-		ts, _, ok = strings.Cut(ts, "]")
+		ip, ts, ok := parseAccessLogLine(unsafeString(scanner.Bytes()))
 		if !ok {
 			continue
 		}
 
-		eventTime, err := time.Parse(config.TimestampLayout, ts)
-		if err == nil && !eventTime.IsZero() {
-			if !useEventTime {
-				useEventTime = true
-				m.timestampsFromData.Store(true)
-			}
-			if config.Replay && !prevEvent.IsZero() {
-				sleep := time.Duration(float64(eventTime.Sub(prevEvent)) / config.ReplaySpeed)
-				if sleep > 0 {
-					if config.ReplayMaxSleep > 0 && sleep > config.ReplayMaxSleep {
-						sleep = config.ReplayMaxSleep
-					}
-					time.Sleep(sleep)
-				}
+		eventTime, err := timestamps.parse(ts)
+		if err == nil {
+			if skip, stop := m.inTimeRange(eventTime); stop {
+				return nil
+			} else if skip {
+				continue
 			}
-			prevEvent = eventTime
-			m.metrics.observeEventTime(eventTime)
-			last = m.doSketchTicks(eventTime, last)
-			m.mu.Lock()
-			m.latestTick = last
-			m.mu.Unlock()
+		}
+		if err == nil && !eventTime.IsZero() {
+			m.countEvent(clock, eventTime, ip, 1)
 		} else if config.Replay {
 			return fmt.Errorf("replay enabled but access-log record has missing/invalid timestamp")
+		} else {
+			m.ingest(m.normalizer.Load().apply(ip), 1)
 		}
 
-		now := time.Now()
-		m.sketchMu.Lock()
-		m.sketch.Incr(ip)
-		m.sketchMu.Unlock()
-		m.metrics.observeIngest(now)
-
 		n++
 		if !config.Replay && config.Pace > 0 {
 			time.Sleep(config.Pace)
@@ -582,6 +848,106 @@ func (m *model) readAccessLogItems(r io.Reader) error {
 	return nil
 }
 
+// eventClock tracks event time for a timestamped input. It belongs to the
+// reader goroutine, except that the sketch ticker may advance an idle clock.
+type eventClock struct {
+	started bool // a valid timestamp has been seen
+
+	mu        sync.Mutex
+	prevEvent time.Time // previous record's event time, for replay pacing
+	arrival   time.Time // wall time at which prevEvent was read
+	last      time.Time // time of the last sketch tick
+
+	behind bool // after a backward jump, until records are current again
+
+	// Reorder buffer for -allowed-lateness. pendingMu also serializes
+	// releasing records from it, by the reader or by advanceIdleClock.
+	pendingMu sync.Mutex
+	pending   eventQueue
+	maxSeen   time.Time
+	seq       uint64
+}
+
+// newEventClock returns the clock for a new timestamped reader.
+func (m *model) newEventClock() *eventClock {
+	clock := &eventClock{}
+	m.eventClock.Store(clock)
+	return clock
+}
+
+func (c *eventClock) lastTick() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.last
+}
+
+// observeEventTime advances the sketch to the record's event time, first
+// sleeping for the (scaled) gap to the previous record when replaying.
+func (m *model) observeEventTime(clock *eventClock, eventTime time.Time) {
+	if !clock.started {
+		clock.started = true
+		m.timestampsFromData.Store(true)
+	}
+	clock.mu.Lock()
+	last, prevEvent := clock.last, clock.prevEvent
+	clock.mu.Unlock()
+	if tick := eventTime.Truncate(config.TickSize); !last.IsZero() && tick.After(last) {
+		m.reachedTick(tick)
+	}
+	// Don't sleep through a gap that empties the window anyway.
+	if gap := eventTime.Sub(prevEvent); config.Replay && !prevEvent.IsZero() && gap <= config.WindowSize {
+		m.replayWait(gap)
+	}
+	m.replay.position.Store(eventTime.UnixNano())
+	m.metrics.observeEventTime(eventTime)
+	clock.mu.Lock()
+	clock.prevEvent, clock.arrival = eventTime, time.Now()
+	clock.last = m.doSketchTicks(eventTime, clock.last)
+	last = clock.last
+	clock.mu.Unlock()
+	m.mu.Lock()
+	m.setLatestTickLocked(last)
+	m.mu.Unlock()
+}
+
+// advanceIdleClock implements -idle-advance: once the input has been quiet
+// for that long, event time (the watermark) follows the wall clock from the
+// last record, so the window keeps sliding and old items age out.
+func (m *model) advanceIdleClock(now time.Time) {
+	clock := m.eventClock.Load()
+	if config.IdleAdvance <= 0 || config.Replay || clock == nil {
+		return
+	}
+	if config.AllowedLateness > 0 {
+		// Held records behind the new event time would be late once it has
+		// moved past them, so release them first.
+		clock.pendingMu.Lock()
+		defer clock.pendingMu.Unlock()
+	}
+	clock.mu.Lock()
+	idle := now.Sub(clock.arrival)
+	if clock.arrival.IsZero() || idle < config.IdleAdvance {
+		clock.mu.Unlock()
+		return
+	}
+	prevEvent, arrival := clock.prevEvent, clock.arrival
+	watermark := prevEvent.Add(idle)
+	clock.mu.Unlock()
+	if m.releaseEvents(clock, watermark) {
+		// Event time still follows the clock from the last record read.
+		clock.mu.Lock()
+		clock.prevEvent, clock.arrival = prevEvent, arrival
+		clock.mu.Unlock()
+	}
+	clock.mu.Lock()
+	clock.last = m.doSketchTicks(watermark, clock.last)
+	last := clock.last
+	clock.mu.Unlock()
+	m.mu.Lock()
+	m.setLatestTickLocked(last)
+	m.mu.Unlock()
+}
+
 func (m *model) sketchTickCmd() tui.Cmd {
 	return func() tui.Msg {
 		var last time.Time
@@ -594,11 +960,12 @@ func (m *model) sketchTickCmd() tui.Cmd {
 			case t := <-ticker.C:
 				m.waitIfPaused()
 				if m.timestampsFromData.Load() {
+					m.advanceIdleClock(t)
 					continue
 				}
 				t = t.Truncate(config.TickSize)
 				m.mu.Lock()
-				m.latestTick = t
+				m.setLatestTickLocked(t)
 				m.mu.Unlock()
 				last = m.doSketchTicks(t, last)
 			}
@@ -613,9 +980,24 @@ func (m *model) doSketchTicks(t time.Time, last time.Time) time.Time {
 		return last
 	}
 	if ticks := int(t.Sub(last) / config.TickSize); ticks > 0 {
-		m.sketchMu.Lock()
-		m.sketch.Ticks(ticks)
-		m.sketchMu.Unlock()
+		m.flushIngest()
+		// Ticking costs time proportional to the ticks; past a whole window
+		// everything has expired, so just empty the sketch.
+		if gap := t.Sub(last); gap > config.WindowSize {
+			m.emptySketch()
+			m.recordClockEvent(clockGap, t, gap)
+			m.mu.Lock()
+			m.startTick = time.Time{}
+			m.mu.Unlock()
+		} else {
+			m.sketchMu.Lock()
+			m.sketch.Ticks(ticks)
+			m.total.tick(ticks)
+			if m.verifier != nil {
+				m.verifier.exact.tick(ticks)
+			}
+			m.sketchMu.Unlock()
+		}
 		last = t
 	}
 	return last
@@ -651,7 +1033,10 @@ func doPlotTick() tui.Cmd {
 type errMsg struct{ err error }
 
 func (m *model) Init() tui.Cmd {
-	return tui.Batch(m.sketchTickCmd(), m.readAndCountInput(), doPlotTick(), doItemsTick(), doItemCountsTick())
+	itemCountsTick := doItemCountsTick()
+	m.itemCountsTicking = itemCountsTick != nil
+	m.reloadSignals = notifyReload()
+	return tui.Batch(m.sketchTickCmd(), m.readAndCountInput(), doPlotTick(), doItemsTick(), itemCountsTick, m.watchConfigCmd())
 }
 
 func (m *model) Update(msg tui.Msg) (tui.Model, tui.Cmd) {
@@ -661,53 +1046,63 @@ func (m *model) Update(msg tui.Msg) (tui.Model, tui.Cmd) {
 		m.err = msg.err
 		m.mu.Unlock()
 		return m, nil
+	case configReloadMsg:
+		if msg.err != nil {
+			m.setNotice("config reload failed: " + msg.err.Error())
+			return m, m.watchConfigCmd()
+		}
+		return m, tui.Batch(m.applyConfig(msg.config), m.watchConfigCmd())
 	case ItemCountsTickMsg:
+		next := doItemCountsTick()
+		m.itemCountsTicking = next != nil
 		if m.isPaused() {
-			return m, doItemCountsTick()
+			return m, next
 		}
+		m.flushIngest()
 		m.updateListItemCountsFromSketch()
 		m.list.Update(msg)
 		cmdList := m.updateList(msg)
-		return m, tui.Batch(cmdList, doItemCountsTick())
+		return m, tui.Batch(cmdList, next)
 	case ItemsTickMsg:
-		if m.isPaused() {
+		if m.isPaused() && !m.replay.refresh.CompareAndSwap(true, false) {
 			return m, doItemsTick()
 		}
+		m.flushIngest()
 		m.updateTopKIncremental()
+		m.updateTrends()
+		m.updateJournal()
+		m.recordSnapshot()
+		m.evaluateAlerts(time.Time(msg))
+		m.verifyTopK()
 		cmdList := m.updateList(msg)
 		return m, tui.Batch(cmdList, doItemsTick())
 	case PlotTickMsg:
+		if m.markerRow != m.hasClockEvents() {
+			m.markerRow = !m.markerRow
+			m.layout()
+		}
+		if m.noticeRow != (m.currentNotice() != "") {
+			m.noticeRow = !m.noticeRow
+			m.layout()
+		}
+		if len(m.statsBlock()) != m.statsRows {
+			m.layout()
+		}
+		m.flushIngest()
 		cmdPlot := m.updatePlot(msg)
 		return m, tui.Batch(cmdPlot, doPlotTick())
+	case tui.MouseMsg:
+		return m, m.handleMouse(msg)
 	case tui.WindowSizeMsg:
 		m.width, m.height = msg.Width, msg.Height
-		m.leftPaneWidth, m.rightPaneWidth = computePaneWidths(m.width, config.ViewSplit)
-		statsLines := 0
-		if config.StatsEnabled {
-			// title + 6 metric lines
-			statsLines = 7
-		}
-		helpLines := 1
-		bottomLines := statsLines + helpLines
-		available := m.height - bottomLines
-		available = max(1, available)
-
-		leftW := max(1, m.leftWidth())
-		rightW := max(1, m.rightWidth())
-
-		m.list.SetSize(leftW, available)
-		m.list.Styles.Title = styles.NewStyle()
-		m.list.Styles.PaginationStyle = styles.NewStyle()
-		m.list.Styles.HelpStyle = styles.NewStyle()
-		m.listStyle = styles.NewStyle().Width(leftW).Height(available)
-
-		// Right side is: plot canvas + 1 label line, wrapped in a border (adds 2 lines).
-		plotHeight := available - 3
-		plotHeight = max(1, plotHeight)
-		plotWidth := max(1, rightW-2)
-		m.resizePlot(plotWidth, plotHeight)
+		m.layout()
 		return m, nil
 	case tui.KeyMsg:
+		// Any key dismisses the notice.
+		m.setNotice("")
+		if m.lookup.editing && msg.Type != tui.KeyCtrlC {
+			return m, m.updateLookup(msg)
+		}
 		switch {
 		case key.Matches(msg, keys.Quit):
 			m.shutdown()
@@ -721,6 +1116,59 @@ func (m *model) Update(msg tui.Msg) (tui.Model, tui.Cmd) {
 		case key.Matches(msg, keys.Scale):
 			m.toggleScale()
 			return m, nil
+		case key.Matches(msg, keys.Sort) && m.list.FilterState() != list.Filtering:
+			m.toggleSort()
+			m.updateTrends()
+			return m, m.updateList(msg)
+		case key.Matches(msg, keys.Chart) && m.list.FilterState() != list.Filtering:
+			m.cycleChart()
+			return m, m.updatePlot(nil)
+		case key.Matches(msg, keys.Journal) && m.list.FilterState() != list.Filtering:
+			m.toggleJournal()
+			return m, nil
+		case key.Matches(msg, keys.Scrub) && len(m.history.snapshots) > 0 && m.list.FilterState() != list.Filtering:
+			m.scrubMode = true
+			m.history.scrub(-1)
+			return m, tui.Batch(m.updateList(nil), m.updatePlot(nil))
+		case key.Matches(msg, keys.Older) && m.scrubMode && m.list.FilterState() != list.Filtering:
+			m.history.scrub(-1)
+			return m, tui.Batch(m.updateList(nil), m.updatePlot(nil))
+		case key.Matches(msg, keys.Newer) && m.scrubMode && m.list.FilterState() != list.Filtering:
+			m.history.scrub(1)
+			return m, tui.Batch(m.updateList(nil), m.updatePlot(nil))
+		case key.Matches(msg, keys.Lookup) && m.list.FilterState() != list.Filtering:
+			return m, m.openLookup()
+		case key.Matches(msg, keys.Pin) && m.showLookup && m.lookup.item != "" && m.list.FilterState() != list.Filtering:
+			m.togglePinLookup()
+			return m, tui.Batch(m.updateList(nil), m.updatePlot(nil))
+		case key.Matches(msg, keys.Pin) && m.list.FilterState() != list.Filtering:
+			m.togglePin()
+			return m, tui.Batch(m.updateList(nil), m.updatePlot(nil))
+		case key.Matches(msg, keys.Detail) && m.list.FilterState() != list.Filtering:
+			m.showDetail = true
+			return m, nil
+		case key.Matches(msg, keys.Live) && m.showLookup:
+			m.showLookup = false
+			return m, nil
+		case key.Matches(msg, keys.Live) && m.showDetail:
+			m.showDetail = false
+			return m, nil
+		case key.Matches(msg, keys.Live) && (m.scrubMode || m.scrubbing() != nil) && m.list.FilterState() != list.Filtering:
+			m.scrubMode = false
+			m.history.scrubAt = time.Time{}
+			return m, tui.Batch(m.updateList(nil), m.updatePlot(nil))
+		case key.Matches(msg, keys.Faster) && config.Replay && m.list.FilterState() != list.Filtering:
+			m.replay.scaleSpeed(2)
+			return m, nil
+		case key.Matches(msg, keys.Slower) && config.Replay && m.list.FilterState() != list.Filtering:
+			m.replay.scaleSpeed(0.5)
+			return m, nil
+		case key.Matches(msg, keys.Step) && config.Replay && m.list.FilterState() != list.Filtering:
+			m.step()
+			return m, nil
+		case key.Matches(msg, keys.Jump) && config.Replay && m.list.FilterState() != list.Filtering:
+			m.jump()
+			return m, nil
 		}
 	}
 	var cmd tui.Cmd
@@ -728,6 +1176,42 @@ func (m *model) Update(msg tui.Msg) (tui.Model, tui.Cmd) {
 	return m, cmd
 }
 
+// layout sizes the list and plot panes for the current terminal size.
+func (m *model) layout() {
+	m.leftPaneWidth, m.rightPaneWidth = computePaneWidths(m.width, config.ViewSplit)
+	// Lines come and go with the input (replay position, clock, verify).
+	m.statsRows = len(m.statsBlock())
+	statsLines := m.statsRows
+	helpLines := 1
+	if m.alerts != nil {
+		helpLines++
+	}
+	bottomLines := statsLines + helpLines
+	if m.noticeRow {
+		bottomLines++
+	}
+	available := m.height - bottomLines
+	available = max(1, available)
+
+	leftW := max(1, m.leftWidth())
+	rightW := max(1, m.rightWidth())
+
+	m.list.SetSize(leftW, available)
+	m.list.Styles.Title = styles.NewStyle()
+	m.list.Styles.PaginationStyle = styles.NewStyle()
+	m.list.Styles.HelpStyle = styles.NewStyle()
+	m.listStyle = styles.NewStyle().Width(leftW).Height(available)
+
+	// Right side is: plot canvas + 1 label line, wrapped in a border (adds 2 lines).
+	plotHeight := available - 3
+	if m.markerRow {
+		plotHeight--
+	}
+	plotHeight = max(1, plotHeight)
+	plotWidth := max(1, rightW-2)
+	m.resizePlot(plotWidth, plotHeight)
+}
+
 func (m *model) toggleTracking() {
 	m.mu.Lock()
 	m.track = !m.track
@@ -753,12 +1237,18 @@ func (m *model) togglePause() {
 func (m *model) isPaused() bool {
 	m.pauseMu.Lock()
 	defer m.pauseMu.Unlock()
-	return m.paused
+	return m.pausedLocked()
+}
+
+// pausedLocked reports whether input is held, with m.pauseMu held. A step
+// or jump lets it run while paused.
+func (m *model) pausedLocked() bool {
+	return m.paused && m.replay.steps == 0 && m.replay.skipUntil.IsZero()
 }
 
 func (m *model) waitIfPaused() {
 	m.pauseMu.Lock()
-	for m.paused && !m.isDone() {
+	for m.pausedLocked() && !m.isDone() {
 		m.pauseCond.Wait()
 	}
 	m.pauseMu.Unlock()
@@ -819,18 +1309,38 @@ func (m *model) updateTopKIncremental() {
 	m.mu.Unlock()
 }
 
+// verifyTopK measures the current ranking against the exact window.
+func (m *model) verifyTopK() {
+	if m.verifier == nil {
+		return
+	}
+	m.mu.Lock()
+	items := cloneItems(m.listItems)
+	m.mu.Unlock()
+	// The list may be ordered by rise.
+	insertionSort(items)
+	m.verifier.observe(m.verifier.exact.measure(items, config.K))
+}
+
 func (m *model) resizePlot(w int, h int) {
-	p := plot.NewCanvas(w, h)
-	p.NumDataPoints = m.plot.NumDataPoints
+	m.plotW, m.plotH = w, h
+	p := plot.NewCanvas(m.canvasSize())
 	p.ShowAxis = m.plot.ShowAxis
 	p.LineColors = m.plot.LineColors
 	m.plot = &p
 }
 
 func (m *model) updateList(msg tui.Msg) tui.Cmd {
+	total := m.windowTotalCount()
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	items := make([]list.Item, len(m.listItems))
+	listItems, trends := m.listItems, m.trends
+	if snap := m.scrubbing(); snap != nil {
+		// Trends are only known for the live leaderboard.
+		listItems, trends, total = snap.heapItems(), nil, snap.total
+	}
+	share, cumulative := shares(listItems, total)
+	items := make([]list.Item, len(listItems))
 	order := make(map[string]int)
 
 	m.listDelegate.Styles.SelectedTitle = m.listDelegate.Styles.SelectedTitle.Bold(m.track)
@@ -840,12 +1350,20 @@ func (m *model) updateList(msg tui.Msg) tui.Cmd {
 	numDecimals := 1 + int(math.Ceil(math.Log10(float64(config.K+1))))
 	padToItemRankWidth := strings.Repeat(" ", numDecimals+1)
 	itemRankFormat := "#%-" + fmt.Sprint(numDecimals) + "d"
-	for i, item := range m.listItems {
-		items[i] = listItem{
+	for i, item := range listItems {
+		li := listItem{
 			DescriptionPrefix: padToItemRankWidth,
 			TitlePrefix:       fmt.Sprintf(itemRankFormat, i+1),
+			Badge:             trends[item.Item].badge(),
 			Item:              item,
 		}
+		if total > 0 {
+			li.Share = formatShare(share[item.Item], cumulative[item.Item])
+		}
+		if pn, ok := m.pins.find(item.Item); ok {
+			li.Pin = colorStyle(pn.color).Render("●")
+		}
+		items[i] = li
 		order[item.Item] = i
 	}
 	selected := m.list.SelectedItem()
@@ -875,41 +1393,77 @@ func (m *model) updatePlot(_ tui.Msg) tui.Cmd {
 	items := make([]heap.Item, len(m.listItems))
 	copy(items, m.listItems)
 	m.mu.Unlock()
+	snap := m.scrubbing()
+	if snap != nil {
+		items = snap.heapItems()
+	}
 	if len(items) == 0 {
 		return nil
 	}
 
-	for i := range m.plotData {
-		m.plotLineColors[i] = dim
+	current := items[selected%len(items)]
+	if m.chartMode != chartLines {
+		m.updateChart(items, current, snap, highlight, dim)
+		return nil
 	}
 	m.sketchMu.Lock()
-	for i := range items {
-		series := m.plotData[i]
-		item := items[(1+selected+i)%len(items)]
-
-		m.fillSeriesFromSketch(item, series, logScale)
+	pinned := m.pinnedItems(items)
+	// Later lines are drawn over earlier ones: a zero line that keeps the
+	// scale's floor at 0, the rest of the top-K, the pinned items and then
+	// the selected item.
+	lines := []heap.Item{{}}
+	colors := []plot.Color{dim}
+	for _, item := range items {
+		if _, ok := m.pins.find(item.Item); !ok && item.Item != current.Item {
+			lines, colors = append(lines, item), append(colors, dim)
+		}
+	}
+	var legend []legendEntry
+	if _, ok := m.pins.find(current.Item); !ok {
+		legend = append(legend, legendEntry{Item: current, color: highlight})
+	}
+	for _, p := range pinned {
+		lines, colors = append(lines, p.Item), append(colors, p.color)
+		legend = append(legend, p)
+	}
+	if _, ok := m.pins.find(current.Item); !ok {
+		lines, colors = append(lines, current), append(colors, highlight)
+	}
+	clear(m.plotData[0])
+	for i, item := range lines[1:] {
+		m.itemSeries(snap, item, m.plotData[i+1], logScale)
 	}
 	m.sketchMu.Unlock()
-	n := len(items)
-	m.plotLineColors[n] = highlight
-	m.plotLineColors[n-1] = dim
-	last := m.plotData[n]
-	for j := range last {
-		last[j] = 0
-	}
-	m.plotData[n], m.plotData[n-1] = m.plotData[n-1], m.plotData[n]
+	n := len(lines)
+	copy(m.plotLineColors, colors)
 	m.mu.Lock()
 	m.plotLineColors, m.plot.LineColors = m.plot.LineColors, m.plotLineColors
+	data := m.fitToCanvas(m.plotData[:n])
+	m.setPlotBounds(data, logScale, legend, dim)
 	m.mu.Unlock()
-	m.plot.Fill(m.plotData[:n+1])
+	m.plot.Fill(data)
 	return nil
 }
 
-func (m *model) fillSeriesFromSketch(item heap.Item, series []float64, logScale bool) {
-	bucketIdx := make([]int, 0, m.sketch.Depth)
-	for k := 0; k < m.sketch.Depth; k++ {
-		idx := topk.BucketIndex(item.Item, k, m.sketch.Width)
-		b := m.sketch.Buckets[idx]
+// itemSeries fills series with item's per-bucket counts, oldest first, from
+// the scrubbed snapshot if any and otherwise from the sketch. Called with the
+// sketch lock held.
+func (m *model) itemSeries(snap *leaderboardSnapshot, item heap.Item, series []float64, logScale bool) {
+	if snap != nil {
+		slot := config.WindowSize / time.Duration(m.sketch.BucketHistoryLength)
+		m.history.series(snap, item.Item, slot, series, logScale)
+		return
+	}
+	fillSeriesFromSketch(m.sketch, item, series, logScale)
+}
+
+// fillSeriesFromSketch writes the item's per-bucket counts into series, oldest
+// first. Called with the sketch lock held.
+func fillSeriesFromSketch(sketch *sliding.Sketch, item heap.Item, series []float64, logScale bool) {
+	bucketIdx := make([]int, 0, sketch.Depth)
+	for k := 0; k < sketch.Depth; k++ {
+		idx := topk.BucketIndex(item.Item, k, sketch.Width)
+		b := sketch.Buckets[idx]
 		if b.Fingerprint == item.Fingerprint && len(b.Counts) > 0 {
 			bucketIdx = append(bucketIdx, idx)
 		}
@@ -925,7 +1479,7 @@ func (m *model) fillSeriesFromSketch(item heap.Item, series []float64, logScale
 	for j := range series {
 		var maxCount uint32
 		for _, idx := range bucketIdx {
-			b := m.sketch.Buckets[idx]
+			b := sketch.Buckets[idx]
 			c := b.Counts[(int(b.First)+j)%len(b.Counts)]
 			maxCount = max(maxCount, c)
 		}
@@ -944,6 +1498,18 @@ func (m *model) View() string {
 	if plot == "" {
 		sb := emptyPlot(m)
 		plot = sb.String()
+	} else if m.showAxes() {
+		plot = m.withAxes(plot)
+	}
+	switch {
+	case m.showLookup:
+		plot = m.lookupView(m.plotW, m.plotH)
+	case m.showDetail:
+		plot = m.detailView(m.plotW, m.plotH)
+	case m.showJournal:
+		plot = m.journal.view(m.plotW, m.plotH)
+	case m.chartMode != chartLines:
+		plot = m.chartView(m.plotW, m.plotH)
 	}
 
 	linColor := borderFg
@@ -956,9 +1522,7 @@ func (m *model) View() string {
 	linLog := linColor.Render("LIN") + " " + logColor.Render("LOG")
 
 	labels := ""
-	m.mu.Lock()
-	latestTick := m.latestTick
-	m.mu.Unlock()
+	latestTick := m.viewTick()
 	if !latestTick.IsZero() {
 		w := m.rightWidth() - 2
 		if w < 0 {
@@ -992,6 +1556,13 @@ func (m *model) View() string {
 		}
 	}
 	right := plotStyle.Render(styles.JoinVertical(styles.Top, plot, labels))
+	if m.markerRow {
+		markers := m.markerLine(max(0, m.rightWidth()-2))
+		if markers == "" {
+			markers = " "
+		}
+		right = plotStyle.Render(styles.JoinVertical(styles.Top, plot, borderFg.Render(markers), labels))
+	}
 	view := styles.JoinHorizontal(styles.Top, left, right)
 
 	m.mu.Lock()
@@ -999,56 +1570,128 @@ func (m *model) View() string {
 	m.mu.Unlock()
 	if err != nil {
 		errStyle := styles.NewStyle().Foreground(styles.AdaptiveColor{Light: "1", Dark: "9"})
-		return styles.JoinVertical(styles.Left, view, errStyle.Render("ERROR: "+err.Error()), m.help.View(keys))
+		return styles.JoinVertical(styles.Left, view, errStyle.Render("ERROR: "+err.Error()), m.help.View(m.helpKeys()))
 	}
-
-	var statsBlock []string
-	if config.StatsEnabled {
-		snap := m.metrics.snapshot()
-		title := "STATS (RUNNING)"
-		if m.isPaused() {
-			title = "STATS (PAUSED)"
+	if m.noticeRow {
+		// Keep the row until the next layout, even if the notice is gone.
+		notice := cutLine(m.currentNotice(), m.width)
+		if notice == "" {
+			notice = " "
 		}
+		view = styles.JoinVertical(styles.Left, view, borderFg.Render(notice))
+	}
+	if m.alerts != nil {
+		line := m.alerts.alertLine(time.Now(), m.width)
+		style := borderFg
+		if strings.HasPrefix(line, "ALERTS") {
+			style = styles.NewStyle().Foreground(styles.AdaptiveColor{Light: "1", Dark: "9"})
+		}
+		view = styles.JoinVertical(styles.Left, view, style.Render(line))
+	}
 
-		topItem := "-"
-		var topCount uint32
-		m.mu.Lock()
-		if len(m.listItems) > 0 {
-			topItem = m.listItems[0].Item
-			topCount = m.listItems[0].Count
+	statsBlock := m.statsBlock()
+	if len(statsBlock) != 0 {
+		statsStyle := styles.NewStyle().Foreground(styles.AdaptiveColor{Light: "1", Dark: "9"})
+		statsText := strings.Join(statsBlock, "\n")
+		return styles.JoinVertical(styles.Left, view, statsStyle.Render(statsText), m.help.View(m.helpKeys()))
+	}
+	return styles.JoinVertical(styles.Left, view, m.help.View(m.helpKeys()))
+}
+
+// statsBlock returns the lines of the STATS block, or nil when it is off.
+func (m *model) statsBlock() []string {
+	if !config.StatsEnabled {
+		return nil
+	}
+	snap := m.metrics.snapshot()
+	title := "STATS (RUNNING)"
+	if m.isPaused() {
+		title = "STATS (PAUSED)"
+	}
+	if snap := m.scrubbing(); snap != nil {
+		title = strings.TrimSuffix(title, ")") + ", VIEWING " + snap.tick.UTC().Format(time.RFC3339) + ")"
+	}
+
+	topName := "-"
+	var topCount uint32
+	windowTotal := m.windowTotalCount()
+	m.mu.Lock()
+	if top, ok := topItem(m.listItems); ok {
+		topName, topCount = top.Item, top.Count
+	}
+	topK := m.listItems
+	m.mu.Unlock()
+	if snap := m.scrubbing(); snap != nil {
+		topK, windowTotal = snap.heapItems(), snap.total
+	}
+	window := fmt.Sprintf("window: %d records", windowTotal)
+	if windowTotal > 0 {
+		var sum uint64
+		for _, item := range topK {
+			sum += uint64(item.Count)
 		}
-		m.mu.Unlock()
+		window += fmt.Sprintf(", top-K %.1f%%", min(100, 100*float64(sum)/float64(windowTotal)))
+	}
 
-		tracked := "off"
-		if m.track {
-			tracked = "-"
-			selected := m.list.SelectedItem()
-			if selected != nil {
-				if li, ok := selected.(listItem); ok {
-					tracked = fmt.Sprintf("%s (%d)", li.Item.Item, li.Count)
-				}
+	tracked := "off"
+	if m.track {
+		tracked = "-"
+		selected := m.list.SelectedItem()
+		if selected != nil {
+			if li, ok := selected.(listItem); ok {
+				tracked = fmt.Sprintf("%s (%d)", li.Item.Item, li.Count)
 			}
 		}
-		statsBlock = []string{
-			title,
-			fmt.Sprintf("records: %d", snap.records),
-			fmt.Sprintf("throughput: %d rec/s", snap.ingestRps),
+	}
+	statsBlock := []string{
+		title,
+		fmt.Sprintf("records: %d", snap.records),
+		fmt.Sprintf("throughput: %d rec/s", snap.ingestRps),
+	}
+	if config.Replay {
+		speed, _ := m.replay.state()
+		line := fmt.Sprintf("replay: %gx", speed)
+		if pos := m.replay.positionTime(); !pos.IsZero() {
+			line += ", at " + pos.UTC().Format(time.RFC3339)
 		}
-		if !snap.lastEventTime.IsZero() {
-			statsBlock = append(statsBlock, fmt.Sprintf("replay position: %s", snap.lastEventTime.UTC().Format(time.RFC3339)))
+		m.pauseMu.Lock()
+		if !m.replay.skipUntil.IsZero() {
+			line += ", jumping to " + m.replay.skipUntil.UTC().Format(time.RFC3339)
+		} else if m.replay.steps > 0 {
+			line += ", stepping"
 		}
-		statsBlock = append(statsBlock,
-			fmt.Sprintf("top-1: %s (%d)", topItem, topCount),
-			fmt.Sprintf("track: %s", tracked),
-		)
+		m.pauseMu.Unlock()
+		statsBlock = append(statsBlock, line)
+	} else if !snap.lastEventTime.IsZero() {
+		statsBlock = append(statsBlock, fmt.Sprintf("replay position: %s", snap.lastEventTime.UTC().Format(time.RFC3339)))
 	}
-
-	if len(statsBlock) != 0 {
-		statsStyle := styles.NewStyle().Foreground(styles.AdaptiveColor{Light: "1", Dark: "9"})
-		statsText := strings.Join(statsBlock, "\n")
-		return styles.JoinVertical(styles.Left, view, statsStyle.Render(statsText), m.help.View(keys))
+	m.sketchMu.Lock()
+	width, depth, history := m.sketch.Width, m.sketch.Depth, m.sketch.BucketHistoryLength
+	sizeBytes := m.sketch.SizeBytes()
+	m.sketchMu.Unlock()
+	epsilon, delta := errorBound(width, depth)
+	statsBlock = append(statsBlock,
+		window,
+		fmt.Sprintf("top-1: %s (%d)", topName, topCount),
+		fmt.Sprintf("track: %s", tracked),
+		fmt.Sprintf("sketch: %dx%d, history %d, %s", width, depth, history, formatBytes(int64(sizeBytes))),
+		fmt.Sprintf("error: ~%.3g%% of window total (Count-Min bound, p >= %.1f%%), ~%d distinct items", 100*epsilon, 100*(1-delta), snap.distinctItems),
+	)
+	if showLateStats() {
+		statsBlock = append(statsBlock, m.late.String())
+	}
+	if line := m.clockStats(); line != "" {
+		statsBlock = append(statsBlock, line)
+	}
+	if m.verifier != nil {
+		a, samples := m.verifier.latest()
+		line := "verify: waiting for first refresh"
+		if samples > 0 {
+			line = "verify: " + a.String()
+		}
+		statsBlock = append(statsBlock, line)
 	}
-	return styles.JoinVertical(styles.Left, view, m.help.View(keys))
+	return statsBlock
 }
 
 func emptyPlot(m *model) strings.Builder {
@@ -1056,13 +1699,14 @@ func emptyPlot(m *model) strings.Builder {
 	if m.width < 2 || m.height < 4 {
 		return sb
 	}
-	w := max(1, m.rightWidth()-2)
-	h := max(1, m.list.Height()-2)
-	sb.Grow(w * h)
+	w, h := m.plotW, m.plotH
+	sb.Grow((w + 1) * h)
 	spaces := strings.Repeat(" ", w)
-	for range h {
+	for i := range h {
+		if i > 0 {
+			sb.WriteRune('\n')
+		}
 		sb.WriteString(spaces)
-		sb.WriteRune('\n')
 	}
 	return sb
 }
@@ -1104,29 +1748,84 @@ func computePaneWidths(totalWidth int, splitPercent int) (left, right int) {
 type listItem struct {
 	DescriptionPrefix string
 	TitlePrefix       string
+	Share             string // share of the window, and of the top-K up to this item
+	Badge             string
+	Pin               string // colored marker of a pinned item
 	heap.Item
 }
 
-func (i listItem) Title() string       { return fmt.Sprintf("%s %s", i.TitlePrefix, i.Item.Item) }
-func (i listItem) Description() string { return fmt.Sprintf("%s %d", i.DescriptionPrefix, i.Count) }
+func (i listItem) Title() string {
+	if i.Pin != "" {
+		return fmt.Sprintf("%s %s %s", i.TitlePrefix, i.Item.Item, i.Pin)
+	}
+	return fmt.Sprintf("%s %s", i.TitlePrefix, i.Item.Item)
+}
+func (i listItem) Description() string {
+	desc := fmt.Sprintf("%s %d", i.DescriptionPrefix, i.Count)
+	if i.Share != "" {
+		desc += "  " + i.Share
+	}
+	if i.Badge != "" {
+		desc += " " + i.Badge
+	}
+	return desc
+}
 func (i listItem) FilterValue() string { return i.Item.Item }
 
 func (k keyMap) ShortHelp() []key.Binding {
-	return []key.Binding{k.Quit, k.Pause, k.Track, k.Scale}
+	if config.Replay {
+		return []key.Binding{k.Quit, k.Pause, k.Track, k.Scale, k.Sort, k.Chart, k.Journal, k.Pin, k.Lookup, k.Scrub, k.Detail, k.Faster, k.Slower, k.Step, k.Jump}
+	}
+	return []key.Binding{k.Quit, k.Pause, k.Track, k.Scale, k.Sort, k.Chart, k.Journal, k.Pin, k.Lookup, k.Scrub, k.Detail}
 }
 
 func (k keyMap) FullHelp() [][]key.Binding {
-	return [][]key.Binding{
+	rows := [][]key.Binding{
 		{k.Quit, k.Pause},
-		{k.Track, k.Scale},
+		{k.Track, k.Pin, k.Scale, k.Sort, k.Chart, k.Journal},
+		{k.Scrub, k.Older, k.Newer, k.Detail, k.Lookup, k.Live},
+	}
+	if config.Replay {
+		rows = append(rows, []key.Binding{k.Faster, k.Slower}, []key.Binding{k.Step, k.Jump})
 	}
+	return rows
+}
+
+// scrubKeyMap is the help shown in scrub mode, where the arrow keys step
+// through history instead of paging the list.
+type scrubKeyMap struct{ keyMap }
+
+func (k scrubKeyMap) ShortHelp() []key.Binding {
+	return []key.Binding{k.Quit, k.Older, k.Newer, k.Live, k.Pause, k.Detail, k.Pin}
+}
+
+// helpKeys returns the key bindings to show help for.
+func (m *model) helpKeys() help.KeyMap {
+	if m.scrubMode {
+		return scrubKeyMap{keys}
+	}
+	return keys
 }
 
 type keyMap struct {
-	Track key.Binding
-	Scale key.Binding
-	Pause key.Binding
-	Quit  key.Binding
+	Track   key.Binding
+	Scale   key.Binding
+	Sort    key.Binding
+	Journal key.Binding
+	Pause   key.Binding
+	Quit    key.Binding
+	Faster  key.Binding
+	Slower  key.Binding
+	Step    key.Binding
+	Jump    key.Binding
+	Scrub   key.Binding
+	Older   key.Binding
+	Newer   key.Binding
+	Live    key.Binding
+	Detail  key.Binding
+	Pin     key.Binding
+	Chart   key.Binding
+	Lookup  key.Binding
 }
 
 var keys = keyMap{
@@ -1138,6 +1837,15 @@ var keys = keyMap{
 		key.WithKeys("s"),
 		key.WithHelp("s", "log/lin"),
 	),
+	Sort: key.NewBinding(
+		key.WithKeys("o"),
+		key.WithHelp("o", "count/rise"),
+	),
+	Journal: key.NewBinding(
+		// Not j, which moves the list cursor down.
+		key.WithKeys("J"),
+		key.WithHelp("J", "journal"),
+	),
 	Pause: key.NewBinding(
 		key.WithKeys("p"),
 		key.WithHelp("p", "pause"),
@@ -1146,4 +1854,53 @@ var keys = keyMap{
 		key.WithKeys("q", "ctrl+c"),
 		key.WithHelp("q/ctrl+c", "quit"),
 	),
+	Faster: key.NewBinding(
+		key.WithKeys("+", "="),
+		key.WithHelp("+", "faster"),
+	),
+	Slower: key.NewBinding(
+		key.WithKeys("-"),
+		key.WithHelp("-", "slower"),
+	),
+	Step: key.NewBinding(
+		key.WithKeys("."),
+		key.WithHelp(".", "step tick"),
+	),
+	Jump: key.NewBinding(
+		key.WithKeys(">"),
+		key.WithHelp(">", "jump"),
+	),
+	Scrub: key.NewBinding(
+		key.WithKeys("["),
+		key.WithHelp("[", "scrub"),
+	),
+	// Only in scrub mode; otherwise the arrow keys page the list.
+	Older: key.NewBinding(
+		key.WithKeys("left"),
+		key.WithHelp("←", "older"),
+	),
+	Newer: key.NewBinding(
+		key.WithKeys("right"),
+		key.WithHelp("→", "newer"),
+	),
+	Live: key.NewBinding(
+		key.WithKeys("esc"),
+		key.WithHelp("esc", "close/live"),
+	),
+	Detail: key.NewBinding(
+		key.WithKeys("enter"),
+		key.WithHelp("enter", "details"),
+	),
+	Pin: key.NewBinding(
+		key.WithKeys("m"),
+		key.WithHelp("m", "pin"),
+	),
+	Chart: key.NewBinding(
+		key.WithKeys("c"),
+		key.WithHelp("c", "chart"),
+	),
+	Lookup: key.NewBinding(
+		key.WithKeys("i"),
+		key.WithHelp("i", "lookup"),
+	),
 }
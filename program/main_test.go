@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// accessLogLayout is the timestamp layout of common log format lines.
+const accessLogLayout = "02/Jan/2006:15:04:05 -0700"
+
+// setConfig applies set to the global config for the rest of the test.
+func setConfig(tb testing.TB, set func(c *Config)) {
+	tb.Helper()
+	saved := config
+	tb.Cleanup(func() { config = saved })
+	set(&config)
+}
+
+// testItems returns n client IPs drawn from a skewed pool, so that a few
+// items dominate like in real traffic.
+func testItems(n int) []string {
+	r := rand.New(rand.NewSource(1))
+	zipf := rand.NewZipf(r, 1.2, 1, 9999)
+	items := make([]string, n)
+	for i := range items {
+		v := zipf.Uint64()
+		items[i] = fmt.Sprintf("10.%d.%d.%d", v>>16&255, v>>8&255, v&255)
+	}
+	return items
+}
+
+// textInput returns n lines of plain items.
+func textInput(n int) []byte {
+	var buf bytes.Buffer
+	for _, item := range testItems(n) {
+		buf.WriteString(item)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
+
+// accessLogInput returns n access-log lines, ten per second of event time.
+func accessLogInput(n int) []byte {
+	var buf bytes.Buffer
+	start := time.Date(2024, 1, 22, 3, 56, 0, 0, time.UTC)
+	for i, item := range testItems(n) {
+		ts := start.Add(time.Duration(i) * 100 * time.Millisecond).Format(accessLogLayout)
+		fmt.Fprintf(&buf, "%s - - [%s] \"GET /image/%d.png HTTP/1.1\" 200 5667 \"-\" \"Mozilla/5.0\"\n", item, ts, i%100)
+	}
+	return buf.Bytes()
+}
+
+// benchmarkReader times read over b.N records of input, so the reported
+// ns/op and allocs/op are per record.
+func benchmarkReader(b *testing.B, input func(int) []byte, read func(*model, *bytes.Reader) error) {
+	setConfig(b, func(c *Config) {
+		c.K = 50
+		c.TickSize = time.Minute
+		c.WindowSize = time.Hour
+		c.TimestampLayout = accessLogLayout
+		c.Replay, c.Pace, c.MaxLines = false, 0, 0
+	})
+	m := newModel(newSketch(&config))
+	r := bytes.NewReader(input(b.N))
+	b.ReportAllocs()
+	b.ResetTimer()
+	if err := read(m, r); err != nil {
+		b.Fatal(err)
+	}
+	m.flushIngest()
+	b.StopTimer()
+	b.ReportMetric(float64(b.N)/b.Elapsed().Seconds(), "rec/s")
+}
+
+func BenchmarkReadTextItems(b *testing.B) {
+	benchmarkReader(b, textInput, func(m *model, r *bytes.Reader) error { return m.readTextItems(r) })
+}
+
+func BenchmarkReadAccessLogItems(b *testing.B) {
+	benchmarkReader(b, accessLogInput, func(m *model, r *bytes.Reader) error { return m.readAccessLogItems(r) })
+}
+
+func BenchmarkReadLinesParallel(b *testing.B) {
+	inputs := []struct {
+		name      string
+		input     func(int) []byte
+		accessLog bool
+	}{
+		{"text", textInput, false},
+		{"access-log", accessLogInput, true},
+	}
+	for _, in := range inputs {
+		for _, workers := range []int{1, 2, 4} {
+			b.Run(fmt.Sprintf("%s/workers=%d", in.name, workers), func(b *testing.B) {
+				benchmarkReader(b, in.input, func(m *model, r *bytes.Reader) error {
+					return m.readLinesParallel(r, workers, in.accessLog)
+				})
+			})
+		}
+	}
+}
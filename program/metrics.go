@@ -17,6 +17,8 @@ type latencyMetrics struct {
 	rateCounter    int64
 	rateLastBucket int64
 	rateBuckets    *int64Ring
+
+	cardinality *cardinalityEstimator
 }
 
 func newLatencyMetrics(window int) *latencyMetrics {
@@ -25,24 +27,25 @@ func newLatencyMetrics(window int) *latencyMetrics {
 	}
 	return &latencyMetrics{
 		rateBuckets: newInt64Ring(window),
+		cardinality: newCardinalityEstimator(),
 	}
 }
 
 func (m *latencyMetrics) setEnabled(v bool) { m.enabled.Store(v) }
 
-func (m *latencyMetrics) observeIngest(now time.Time) {
+func (m *latencyMetrics) observeIngest(now time.Time, n int) {
 	if !m.enabled.Load() {
 		return
 	}
 	if now.IsZero() {
 		now = time.Now()
 	}
-	m.ingestedRecords.Add(1)
+	m.ingestedRecords.Add(uint64(n))
 
 	bucket := now.Unix()
 	m.mu.Lock()
 	if bucket == m.rateLastBucket {
-		m.rateCounter++
+		m.rateCounter += int64(n)
 	} else {
 		if m.rateLastBucket > 0 {
 			m.rateBuckets.add(m.rateCounter)
@@ -56,11 +59,17 @@ func (m *latencyMetrics) observeIngest(now time.Time) {
 			}
 		}
 		m.rateLastBucket = bucket
-		m.rateCounter = 1
+		m.rateCounter = int64(n)
 	}
 	m.mu.Unlock()
 }
 
+func (m *latencyMetrics) observeItem(item string) {
+	if m.enabled.Load() {
+		m.cardinality.observe(item)
+	}
+}
+
 func (m *latencyMetrics) observeEventTime(t time.Time) {
 	if !t.IsZero() {
 		m.lastEventTimeNs.Store(t.UnixNano())
@@ -71,6 +80,7 @@ type snapshot struct {
 	records       uint64
 	ingestRps     int64
 	lastEventTime time.Time
+	distinctItems uint64
 }
 
 func (m *latencyMetrics) snapshot() snapshot {
@@ -94,6 +104,7 @@ func (m *latencyMetrics) snapshot() snapshot {
 		records:       records,
 		ingestRps:     rps,
 		lastEventTime: lastEventTime,
+		distinctItems: m.cardinality.estimate(),
 	}
 }
 
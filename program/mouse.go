@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/charmbracelet/bubbles/list"
+	tui "github.com/charmbracelet/bubbletea"
+)
+
+// Mouse modes for -mouse.
+const (
+	mouseCell = "cell" // clicks, wheel, and motion while a button is held
+	mouseAll  = "all"  // also plain motion, for hovering; fewer terminals support it
+	mouseOff  = "off"
+)
+
+// mouseOption returns the program option for config.Mouse, if any.
+func mouseOption() (tui.ProgramOption, bool) {
+	switch config.Mouse {
+	case mouseCell:
+		return tui.WithMouseCellMotion(), true
+	case mouseAll:
+		return tui.WithMouseAllMotion(), true
+	}
+	return nil, false
+}
+
+// hover is the plot column under the mouse pointer. Only used by the update
+// loop.
+type hover struct {
+	col    int // canvas column
+	active bool
+}
+
+// handleMouse selects and scrolls list rows, and points the plot tooltip at
+// the column under the pointer.
+func (m *model) handleMouse(msg tui.MouseMsg) tui.Cmd {
+	m.hover.active = false
+	if msg.X >= m.leftWidth() {
+		m.hoverPlot(msg.X-m.leftWidth(), msg.Y)
+		return nil
+	}
+	switch {
+	case msg.Button == tui.MouseButtonWheelUp:
+		m.list.CursorUp()
+	case msg.Button == tui.MouseButtonWheelDown:
+		m.list.CursorDown()
+	case msg.Button == tui.MouseButtonLeft && msg.Action == tui.MouseActionPress:
+		i, ok := m.listItemAt(msg.Y)
+		if !ok {
+			return nil
+		}
+		m.list.Select(i)
+	default:
+		return nil
+	}
+	return m.updatePlot(nil)
+}
+
+// listItemAt returns the index among the visible items of the list row at
+// screen row y.
+func (m *model) listItemAt(y int) (int, bool) {
+	visible := m.list.VisibleItems()
+	top := m.listHeaderHeight()
+	if len(visible) == 0 || y < top {
+		return 0, false
+	}
+	start, end := m.list.Paginator.GetSliceBounds(len(visible))
+	height := m.listDelegate.Height()
+	rows := height + m.listDelegate.Spacing()
+	i := start + (y-top)/rows
+	if (y-top)%rows >= height || i >= end {
+		return 0, false
+	}
+	return i, true
+}
+
+// listHeaderHeight returns the rows the list draws above its items, as
+// list.Model.View lays them out: the title bar, which also holds the filter
+// input, and the status bar.
+func (m *model) listHeaderHeight() int {
+	l := &m.list
+	rows := 0
+	if l.ShowTitle() || l.ShowFilter() && l.FilteringEnabled() {
+		if l.ShowTitle() || l.FilterState() == list.Filtering {
+			rows += l.Styles.TitleBar.GetVerticalFrameSize() + 1
+		} else {
+			// The empty title bar still takes a line.
+			rows++
+		}
+	}
+	if l.ShowStatusBar() {
+		rows += l.Styles.StatusBar.GetVerticalFrameSize() + 1
+	}
+	return rows
+}
+
+// hoverPlot points the tooltip at the canvas column under x, y, relative to
+// the plot pane's border. Only the time-based charts with axes have one.
+func (m *model) hoverPlot(x, y int) {
+	if !m.showAxes() || m.showLookup || m.showDetail || m.showJournal ||
+		(m.chartMode != chartLines && m.chartMode != chartStacked) {
+		return
+	}
+	cw, ch := m.canvasSize()
+	// Border, then the legend row above the canvas and the Y axis left of it.
+	col, row := x-1-yAxisWidth, y-2
+	if col < 0 || col >= cw || row < 0 || row >= ch {
+		return
+	}
+	m.hover = hover{col: col, active: true}
+}
+
+// tooltip describes the buckets under the hovered column: their time range
+// and the selected item's count there.
+func (m *model) tooltip(cw int) string {
+	selected, ok := m.list.SelectedItem().(listItem)
+	tick := m.viewTick()
+	if !ok || tick.IsZero() {
+		return ""
+	}
+	snap := m.scrubbing()
+	m.sketchMu.Lock()
+	n := m.sketch.BucketHistoryLength
+	series := make([]float64, n)
+	m.itemSeries(snap, selected.Item, series, false)
+	m.sketchMu.Unlock()
+
+	// The same buckets the column was resampled from.
+	from := m.hover.col * n / cw
+	to := min(n, max((m.hover.col+1)*n/cw, from+1))
+	var count float64
+	for _, v := range series[from:to] {
+		count = max(count, v)
+	}
+	slot := config.WindowSize / time.Duration(n)
+	start := tick.Add(-time.Duration(n-1-from) * slot)
+	end := tick.Add(-time.Duration(n-to) * slot).Add(slot)
+	const clock = "15:04:05"
+	s := fmt.Sprintf("%s–%s  %s: %d", start.UTC().Format(clock), end.UTC().Format(clock), selected.Item.Item, int(count))
+	if to-from > 1 {
+		s += fmt.Sprintf(" (max of %d buckets)", to-from)
+	}
+	return s
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/keilerkonzept/topk/heap"
+)
+
+func TestListItemAt(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(l *list.Model)
+	}{
+		{"search enabled", func(l *list.Model) {}},
+		{"search disabled", func(l *list.Model) { l.SetFilteringEnabled(false) }},
+		{"filtering", func(l *list.Model) { l.SetFilterState(list.Filtering) }},
+		{"filter applied", func(l *list.Model) { l.SetFilterText("1") }},
+		{"title and status bar", func(l *list.Model) {
+			l.SetShowTitle(true)
+			l.SetShowStatusBar(true)
+		}},
+		{"second page", func(l *list.Model) { l.Paginator.NextPage() }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setConfig(t, func(c *Config) { c.SearchEnabled = true })
+			m := newModel(newSketch(&config))
+			m.width, m.height = 100, 30
+			m.layout()
+			// Item 1 is a prefix of items 10 to 19.
+			items := make([]list.Item, 25)
+			for i := range items {
+				items[i] = listItem{
+					TitlePrefix: fmt.Sprintf("#%-2d", i+1),
+					Item:        heap.Item{Item: fmt.Sprint(i + 1), Count: uint32(100 - i)},
+				}
+			}
+			m.list.SetItems(items)
+			tt.setup(&m.list)
+
+			// The rows of the items on the current page, found in the rendered list.
+			lines := strings.Split(m.list.View(), "\n")
+			visible := m.list.VisibleItems()
+			start, end := m.list.Paginator.GetSliceBounds(len(visible))
+			want := make(map[int]int) // screen row to item index
+			for i := start; i < end; i++ {
+				title := visible[i].(listItem).Title()
+				for y, line := range lines {
+					if strings.HasSuffix(strings.TrimRight(line, " "), title) {
+						for r := range m.listDelegate.Height() {
+							want[y+r] = i
+						}
+						break
+					}
+				}
+			}
+			if len(want) != (end-start)*m.listDelegate.Height() {
+				t.Fatalf("found %d item rows in the list view, want %d", len(want), (end-start)*m.listDelegate.Height())
+			}
+			for y := range lines {
+				i, ok := m.listItemAt(y)
+				wantI, wantOK := want[y]
+				if ok != wantOK || ok && i != wantI {
+					t.Errorf("listItemAt(%d) = %d, %v, want %d, %v (line %q)", y, i, ok, wantI, wantOK, lines[y])
+				}
+			}
+		})
+	}
+}
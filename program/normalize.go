@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bufio"
+	"net/netip"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// stringList is a repeatable string flag.
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, ", ") }
+
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+// normalizer applies a chain of item transforms before items reach the sketch.
+// A nil normalizer leaves items unchanged.
+type normalizer struct {
+	specs      []string
+	transforms []func(string) string
+}
+
+// newNormalizer compiles transform specs, applied in the given order:
+//
+//	ipv4-prefix=N     truncate IPv4 addresses to a /N prefix (e.g. 24)
+//	ipv6-prefix=N     truncate IPv6 addresses to a /N prefix (e.g. 64)
+//	path-template     replace numeric and UUID path segments with :num / :uuid
+//	strip-query       drop everything from the first '?' or '#'
+//	lower             fold the item to lower case
+//	regex=RE=>REPL    replace all matches of RE with REPL ($1 etc. expand)
+func newNormalizer(specs []string) (*normalizer, error) {
+	if len(specs) == 0 {
+		return nil, nil
+	}
+	n := &normalizer{specs: append([]string(nil), specs...)}
+	for _, spec := range specs {
+		fn, err := parseTransform(spec)
+		if err != nil {
+			return nil, err
+		}
+		n.transforms = append(n.transforms, fn)
+	}
+	return n, nil
+}
+
+func (n *normalizer) apply(item string) string {
+	if n == nil {
+		return item
+	}
+	for _, fn := range n.transforms {
+		item = fn(item)
+	}
+	return item
+}
+
+func parseTransform(spec string) (func(string) string, error) {
+	name, arg, hasArg := strings.Cut(strings.TrimSpace(spec), "=")
+	switch name {
+	case "ipv4-prefix", "ipv6-prefix":
+		maxBits := 32
+		if name == "ipv6-prefix" {
+			maxBits = 128
+		}
+		bits, err := strconv.Atoi(strings.TrimPrefix(arg, "/"))
+		if !hasArg || err != nil || bits < 0 || bits > maxBits {
+			return nil, flagErrorf("normalize", "%q: prefix length must be in [0,%d]", spec, maxBits)
+		}
+		return ipPrefixTransform(name == "ipv6-prefix", bits), nil
+	case "path-template":
+		return templatePath, nil
+	case "strip-query":
+		return stripQuery, nil
+	case "lower":
+		return strings.ToLower, nil
+	case "regex":
+		pattern, repl, ok := strings.Cut(arg, "=>")
+		if !hasArg || !ok {
+			return nil, flagErrorf("normalize", "%q: expected regex=PATTERN=>REPLACEMENT", spec)
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, flagErrorf("normalize", "%q: %v", spec, err)
+		}
+		return func(item string) string { return re.ReplaceAllString(item, repl) }, nil
+	}
+	return nil, flagErrorf("normalize", "%q: unknown transform (use ipv4-prefix, ipv6-prefix, path-template, strip-query, lower or regex)", spec)
+}
+
+// ipPrefixTransform truncates items that parse as an address of the given
+// family to their network prefix. Other items pass through unchanged.
+func ipPrefixTransform(v6 bool, bits int) func(string) string {
+	return func(item string) string {
+		addr, err := netip.ParseAddr(item)
+		if err != nil {
+			return item
+		}
+		addr = addr.Unmap()
+		if addr.Is6() != v6 {
+			return item
+		}
+		prefix, err := addr.WithZone("").Prefix(bits)
+		if err != nil {
+			return item
+		}
+		return prefix.String()
+	}
+}
+
+func stripQuery(item string) string {
+	if i := strings.IndexAny(item, "?#"); i >= 0 {
+		return item[:i]
+	}
+	return item
+}
+
+// templatePath rewrites numeric and UUID segments of the path in the item, so
+// /product/123 and /product/456 both become /product/:num. The path is the
+// item itself if it starts with '/', otherwise the first space-separated field
+// that does (as in "GET /product/123 HTTP/1.1").
+func templatePath(item string) string {
+	start := 0
+	if !strings.HasPrefix(item, "/") {
+		i := strings.Index(item, " /")
+		if i < 0 {
+			return item
+		}
+		start = i + 1
+	}
+	end := len(item)
+	if i := strings.IndexAny(item[start:], "?# "); i >= 0 {
+		end = start + i
+	}
+	segments := strings.Split(item[start:end], "/")
+	changed := false
+	for i, s := range segments {
+		switch {
+		case isNumeric(s):
+			segments[i] = ":num"
+			changed = true
+		case isUUID(s):
+			segments[i] = ":uuid"
+			changed = true
+		}
+	}
+	if !changed {
+		return item
+	}
+	return item[:start] + strings.Join(segments, "/") + item[end:]
+}
+
+func isNumeric(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func isUUID(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch i {
+		case 8, 13, 18, 23:
+			if c != '-' {
+				return false
+			}
+		default:
+			if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// readNormalizeFile reads transform specs, one per line. Blank lines and lines
+// starting with '#' are ignored.
+func readNormalizeFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+	var specs []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		specs = append(specs, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return specs, nil
+}
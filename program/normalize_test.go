@@ -0,0 +1,96 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNormalizer(t *testing.T) {
+	tests := []struct {
+		specs []string
+		item  string
+		want  string
+	}{
+		{nil, "Anything?at=all", "Anything?at=all"},
+
+		{[]string{"ipv4-prefix=24"}, "192.168.1.77", "192.168.1.0/24"},
+		{[]string{"ipv4-prefix=8"}, "10.1.2.3", "10.0.0.0/8"},
+		{[]string{"ipv4-prefix=/16"}, "10.1.2.3", "10.1.0.0/16"},
+		{[]string{"ipv4-prefix=0"}, "10.1.2.3", "0.0.0.0/0"},
+		{[]string{"ipv4-prefix=32"}, "10.1.2.3", "10.1.2.3/32"},
+		{[]string{"ipv4-prefix=24"}, "::ffff:192.168.1.77", "192.168.1.0/24"}, // IPv4-mapped
+		{[]string{"ipv4-prefix=24"}, "300.1.2.3", "300.1.2.3"},
+		{[]string{"ipv4-prefix=24"}, "192.168.1.77 ", "192.168.1.77 "},
+		{[]string{"ipv4-prefix=24"}, "example.com", "example.com"},
+		{[]string{"ipv4-prefix=24"}, "2001:db8::1", "2001:db8::1"},
+
+		{[]string{"ipv6-prefix=64"}, "2001:db8:1:2:3:4:5:6", "2001:db8:1:2::/64"},
+		{[]string{"ipv6-prefix=/48"}, "2001:db8:1:2::1", "2001:db8:1::/48"},
+		{[]string{"ipv6-prefix=64"}, "fe80::1%eth0", "fe80::/64"},
+		{[]string{"ipv6-prefix=64"}, "::ffff:10.0.0.1", "::ffff:10.0.0.1"}, // an IPv4 address
+		{[]string{"ipv6-prefix=64"}, "10.0.0.1", "10.0.0.1"},
+		{[]string{"ipv6-prefix=64"}, "2001:db8::g", "2001:db8::g"},
+
+		{[]string{"path-template"}, "/product/123", "/product/:num"},
+		{[]string{"path-template"}, "/u/123e4567-E89B-12d3-a456-426614174000/orders/7?id=9", "/u/:uuid/orders/:num?id=9"},
+		{[]string{"path-template"}, "GET /product/42 HTTP/1.1", "GET /product/:num HTTP/1.1"},
+		{[]string{"path-template"}, "/v1.2/12a/-1", "/v1.2/12a/-1"},
+		{[]string{"path-template"}, "/product//", "/product//"},
+		{[]string{"path-template"}, "/", "/"},
+		{[]string{"path-template"}, "", ""},
+		{[]string{"path-template"}, "product/123", "product/123"},
+
+		{[]string{"strip-query"}, "/search?q=1#top", "/search"},
+		{[]string{"strip-query"}, "/page#top?x", "/page"},
+		{[]string{"strip-query"}, "?q=1", ""},
+		{[]string{"strip-query"}, "", ""},
+		{[]string{"strip-query"}, "/plain", "/plain"},
+
+		{[]string{"lower"}, "GET /Product", "get /product"},
+
+		{[]string{`regex=^/api/v[0-9]+/=>/api/`}, "/api/v2/users", "/api/users"},
+		{[]string{`regex=^(\w+)@(\w+)\.com$=>$2:$1`}, "bob@example.com", "example:bob"},
+		{[]string{`regex=(?P<user>\w+)@=>${user}-at-`}, "bob@example.com", "bob-at-example.com"},
+		// $1x names group "1x", which doesn't exist; ${1}x doesn't.
+		{[]string{`regex=(a)b=>$1x`}, "ab", ""},
+		{[]string{`regex=(a)b=>${1}x`}, "ab", "ax"},
+		{[]string{`regex=[0-9]+=>#`}, "a1b22c333", "a#b#c#"},
+		{[]string{`regex=^x=>y`}, "no match", "no match"},
+
+		// Transforms apply in order.
+		{[]string{"strip-query", "path-template", "lower"}, "/Product/12?Q=1", "/product/:num"},
+		{[]string{"lower", `regex=^get =>`}, "GET /a", "/a"},
+		{[]string{`regex=^get =>`, "lower"}, "GET /a", "get /a"},
+	}
+	for _, tt := range tests {
+		n, err := newNormalizer(tt.specs)
+		if err != nil {
+			t.Errorf("newNormalizer(%q): %v", tt.specs, err)
+			continue
+		}
+		if got := n.apply(tt.item); got != tt.want {
+			t.Errorf("%q applied to %q = %q, want %q", tt.specs, tt.item, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizerErrors(t *testing.T) {
+	for _, spec := range []string{
+		"ipv4-prefix",
+		"ipv4-prefix=",
+		"ipv4-prefix=33",
+		"ipv4-prefix=x",
+		"ipv6-prefix=129",
+		"ipv6-prefix=-1",
+		"regex",
+		"regex=abc",
+		"regex=(=>x",
+		"upper",
+	} {
+		_, err := newNormalizer([]string{"lower", spec})
+		var fe *flagError
+		if !errors.As(err, &fe) || fe.flag != "normalize" {
+			t.Errorf("newNormalizer(%q) error = %v, want a -normalize error", spec, err)
+		}
+	}
+}
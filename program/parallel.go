@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// parseChunkSize is the amount of input handed to a parse worker at once.
+const parseChunkSize = 1 << 20
+
+// maxLineSize is the longest line accepted, the same limit the sequential
+// readers give their bufio.Scanner.
+const maxLineSize = 1024 * 1024
+
+type parsedRecord struct {
+	item      string
+	eventTime time.Time // zero for text input or an invalid timestamp
+}
+
+type parseChunk struct {
+	seq  int
+	data []byte
+}
+
+type parsedChunk struct {
+	seq     int
+	records []parsedRecord
+}
+
+// parseWorkers returns the configured number of parse goroutines.
+func parseWorkers() int {
+	if config.ParseWorkers == 0 {
+		return runtime.NumCPU()
+	}
+	return config.ParseWorkers
+}
+
+// readLinesParallel is readTextItems/readAccessLogItems with parsing spread
+// over several goroutines. The input is cut into chunks on line boundaries,
+// chunks are parsed concurrently, and the parsed records are counted strictly
+// in input order so that event time still only moves as the file says.
+//
+// Chunks waiting to be counted are held until the chunks before them are
+// parsed, so the chunks in flight, from reading to counting, are capped at
+// twice the workers; otherwise one slow chunk would let the others pile up.
+func (m *model) readLinesParallel(r io.Reader, workers int, accessLog bool) error {
+	stop := make(chan struct{})
+	defer close(stop)
+
+	chunks := make(chan parseChunk, workers)
+	results := make(chan parsedChunk, workers)
+	inFlight := make(chan struct{}, 2*workers)
+	readErr := make(chan error, 1)
+	go func() {
+		defer close(chunks)
+		readErr <- splitChunks(r, chunks, inFlight, stop)
+	}()
+
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for c := range chunks {
+				select {
+				case results <- parsedChunk{seq: c.seq, records: parseLines(c.data, accessLog)}:
+				case <-stop:
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	clock := m.newEventClock()
+	defer m.drainEvents(clock)
+	pending := make(map[int][]parsedRecord)
+	next, n := 0, 0
+	for res := range results {
+		pending[res.seq] = res.records
+		for {
+			records, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			next++
+			for _, rec := range records {
+				if m.isDone() {
+					return nil
+				}
+				m.waitIfPaused()
+				if config.MaxLines > 0 && n >= config.MaxLines {
+					return nil
+				}
+				if skip, stop := m.inTimeRange(rec.eventTime); stop {
+					return nil
+				} else if skip {
+					continue
+				}
+				if !rec.eventTime.IsZero() {
+					m.countEvent(clock, rec.eventTime, rec.item, 1)
+				} else if accessLog && config.Replay {
+					return fmt.Errorf("replay enabled but access-log record has missing/invalid timestamp")
+				} else {
+					m.ingest(m.normalizer.Load().apply(rec.item), 1)
+				}
+				n++
+				if !config.Replay && config.Pace > 0 {
+					time.Sleep(config.Pace)
+				}
+			}
+			<-inFlight
+		}
+	}
+	return <-readErr
+}
+
+// splitChunks reads r into chunks that end on a line boundary. It takes a
+// slot in inFlight for each chunk; the slot is given back once the chunk has
+// been counted.
+func splitChunks(r io.Reader, out chan<- parseChunk, inFlight chan<- struct{}, stop <-chan struct{}) error {
+	var carry []byte
+	for seq := 0; ; seq++ {
+		buf := make([]byte, len(carry), len(carry)+parseChunkSize)
+		copy(buf, carry)
+		n, err := io.ReadFull(r, buf[len(carry):cap(buf)])
+		buf = buf[:len(carry)+n]
+		eof := err == io.EOF || err == io.ErrUnexpectedEOF
+		if err != nil && !eof {
+			return err
+		}
+
+		// Only the first line, which may have started in carry, and the
+		// last, which goes on in the next chunk, can be longer than the
+		// chunk read.
+		first, cut := bytes.IndexByte(buf, '\n'), bytes.LastIndexByte(buf, '\n')
+		if first >= maxLineSize || len(buf)-cut-1 >= maxLineSize {
+			return bufio.ErrTooLong
+		}
+		data := buf
+		carry = nil
+		if !eof {
+			data, carry = buf[:cut+1], buf[cut+1:]
+		}
+		if len(data) > 0 {
+			select {
+			case inFlight <- struct{}{}:
+			case <-stop:
+				return nil
+			}
+			select {
+			case out <- parseChunk{seq: seq, data: data}:
+			case <-stop:
+				return nil
+			}
+		}
+		if eof {
+			return nil
+		}
+	}
+}
+
+// parseLines parses every line of a chunk the same way the sequential
+// readers do. Access log lines that don't match the format are skipped.
+// Items are views of data, which is never reused once parsed.
+func parseLines(data []byte, accessLog bool) []parsedRecord {
+	var timestamps timestampCache
+	records := make([]parsedRecord, 0, bytes.Count(data, []byte{'\n'})+1)
+	for len(data) > 0 {
+		line := data
+		if i := bytes.IndexByte(data, '\n'); i >= 0 {
+			line, data = data[:i], data[i+1:]
+		} else {
+			data = nil
+		}
+		line = bytes.TrimSuffix(line, []byte{'\r'})
+		if !accessLog {
+			records = append(records, parsedRecord{item: unsafeString(line)})
+			continue
+		}
+		ip, ts, ok := parseAccessLogLine(unsafeString(line))
+		if !ok {
+			continue
+		}
+		t, _ := timestamps.parse(ts)
+		records = append(records, parsedRecord{item: ip, eventTime: t})
+	}
+	return records
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/keilerkonzept/topk/heap"
+)
+
+func TestSplitChunks(t *testing.T) {
+	input := textInput(400000)
+	out := make(chan parseChunk)
+	inFlight := make(chan struct{}, 1<<10)
+	done := make(chan error, 1)
+	go func() {
+		defer close(out)
+		done <- splitChunks(bytes.NewReader(input), out, inFlight, nil)
+	}()
+	var joined []byte
+	for seq := 0; ; seq++ {
+		c, ok := <-out
+		if !ok {
+			break
+		}
+		if c.seq != seq {
+			t.Errorf("chunk %d has seq %d", seq, c.seq)
+		}
+		if c.data[len(c.data)-1] != '\n' {
+			t.Errorf("chunk %d does not end on a line boundary", seq)
+		}
+		joined = append(joined, c.data...)
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(joined, input) {
+		t.Errorf("chunks add up to %d bytes, want the %d input bytes", len(joined), len(input))
+	}
+}
+
+func TestSplitChunksLongLines(t *testing.T) {
+	line := func(n int) string { return string(bytes.Repeat([]byte{'x'}, n)) + "\n" }
+	short := line(10)
+	tests := []struct {
+		name  string
+		input string
+		want  error
+	}{
+		{"longest line", short + line(maxLineSize-1) + short, nil},
+		{"longest line across chunks", line(parseChunkSize-100) + line(maxLineSize-1) + short, nil},
+		{"too long", short + line(maxLineSize) + short, bufio.ErrTooLong},
+		{"too long across chunks", line(parseChunkSize-100) + line(maxLineSize) + short, bufio.ErrTooLong},
+		{"too long, first chunk", line(3 * maxLineSize), bufio.ErrTooLong},
+		{"too long at the end", short + line(maxLineSize)[:maxLineSize], bufio.ErrTooLong},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The sequential readers' scanner agrees.
+			scanner := bufio.NewScanner(bytes.NewReader([]byte(tt.input)))
+			scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+			for scanner.Scan() {
+			}
+			if err := scanner.Err(); err != tt.want {
+				t.Fatalf("scanner error %v, want %v", err, tt.want)
+			}
+
+			out := make(chan parseChunk)
+			done := make(chan error, 1)
+			go func() {
+				defer close(out)
+				done <- splitChunks(bytes.NewReader([]byte(tt.input)), out, make(chan struct{}, 100), nil)
+			}()
+			var n int
+			for c := range out {
+				n += len(c.data)
+			}
+			err := <-done
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("error %v, want %v", err, tt.want)
+			}
+			if err == nil && n != len(tt.input) {
+				t.Errorf("chunks add up to %d bytes, want %d", n, len(tt.input))
+			}
+		})
+	}
+}
+
+func TestSplitChunksInFlight(t *testing.T) {
+	const slots = 3
+	input := textInput(400000)
+	out := make(chan parseChunk, 100)
+	inFlight := make(chan struct{}, slots)
+	stop := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		done <- splitChunks(bytes.NewReader(input), out, inFlight, stop)
+	}()
+
+	received := func(want int) {
+		t.Helper()
+		deadline := time.After(5 * time.Second)
+		for len(out) < want {
+			select {
+			case <-deadline:
+				t.Fatalf("%d chunks in flight, want %d", len(out), want)
+			case <-time.After(time.Millisecond):
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		if len(out) != want {
+			t.Fatalf("%d chunks in flight, want %d", len(out), want)
+		}
+	}
+	received(slots)
+	// Counting a chunk frees its slot.
+	<-inFlight
+	received(slots + 1)
+
+	close(stop)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("splitChunks did not stop")
+	}
+}
+
+// exactCounts reads input with read and returns the exact window counts.
+func exactCounts(t *testing.T, input []byte, read func(*model, *bytes.Reader) error) []heap.Item {
+	m := newModel(newSketch(&config))
+	if err := read(m, bytes.NewReader(input)); err != nil {
+		t.Fatal(err)
+	}
+	m.flushIngest()
+	m.sketchMu.Lock()
+	defer m.sketchMu.Unlock()
+	m.verifier.exact.mu.Lock()
+	defer m.verifier.exact.mu.Unlock()
+	return m.verifier.exact.topKLocked(1 << 20)
+}
+
+func TestReadLinesParallel(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      []byte
+		accessLog  bool
+		sequential func(*model, *bytes.Reader) error
+	}{
+		{"text", textInput(300000), false, func(m *model, r *bytes.Reader) error { return m.readTextItems(r) }},
+		{"access log", accessLogInput(30000), true, func(m *model, r *bytes.Reader) error { return m.readAccessLogItems(r) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setConfig(t, func(c *Config) {
+				c.K = 50
+				c.TickSize = time.Minute
+				c.WindowSize = time.Hour
+				c.TimestampLayout = accessLogLayout
+				c.Verify = true
+				c.Replay, c.Pace, c.MaxLines = false, 0, 0
+			})
+			if len(tt.input) < 2*parseChunkSize {
+				t.Fatalf("test input is only %d bytes", len(tt.input))
+			}
+			want := exactCounts(t, tt.input, tt.sequential)
+			for _, workers := range []int{1, 2, 4} {
+				got := exactCounts(t, tt.input, func(m *model, r *bytes.Reader) error {
+					return m.readLinesParallel(r, workers, tt.accessLog)
+				})
+				if !slices.Equal(got, want) {
+					t.Errorf("%d workers: exact counts differ from the sequential reader (%d items, want %d)", workers, len(got), len(want))
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+
+	plot "github.com/chriskim06/drawille-go"
+	"github.com/keilerkonzept/topk"
+	"github.com/keilerkonzept/topk/heap"
+)
+
+// pinPalette holds the colors of pinned items' plot lines, in the order
+// they are handed out. It also caps the number of pins.
+var pinPalette = []plot.Color{
+	plot.DodgerBlue,
+	plot.DarkOrange,
+	plot.Fuchsia,
+	plot.SpringGreen,
+	plot.MediumPurple,
+	plot.Gold,
+}
+
+// pin is an item whose plot line is drawn in its own color.
+type pin struct {
+	item  string
+	color plot.Color
+}
+
+// pins are the pinned items, in the order they were pinned. Pins are kept by
+// name, so they survive re-ranking and an item leaving the top-K.
+// Only used by the update loop.
+type pins []pin
+
+func (p pins) find(item string) (pin, bool) {
+	for _, pn := range p {
+		if pn.item == item {
+			return pn, true
+		}
+	}
+	return pin{}, false
+}
+
+// toggle unpins item if it is pinned, and otherwise pins it in the first
+// free color. It fails when every color is taken.
+func (p *pins) toggle(item string) error {
+	for i, pn := range *p {
+		if pn.item == item {
+			*p = append((*p)[:i], (*p)[i+1:]...)
+			return nil
+		}
+	}
+	for _, c := range pinPalette {
+		free := true
+		for _, pn := range *p {
+			free = free && pn.color != c
+		}
+		if free {
+			*p = append(*p, pin{item: item, color: c})
+			return nil
+		}
+	}
+	return fmt.Errorf("at most %d items can be pinned", len(pinPalette))
+}
+
+// togglePin pins or unpins the selected item.
+func (m *model) togglePin() {
+	selected, ok := m.list.SelectedItem().(listItem)
+	if !ok {
+		return
+	}
+	if err := m.pins.toggle(selected.Item.Item); err != nil {
+		m.setNotice("pin: " + err.Error())
+	}
+}
+
+// legendEntry is an item named in the plot legend.
+type legendEntry struct {
+	heap.Item
+	color plot.Color
+	out   bool // not in the top-K
+}
+
+func (e legendEntry) String() string {
+	if e.out {
+		return fmt.Sprintf("━━ %s (out, %d)", e.Item.Item, e.Count)
+	}
+	return "━━ " + e.Item.Item
+}
+
+// pinnedItems returns the pinned items in pin order. Those that are not in
+// items have left the top-K and are counted with the sketch.
+// Called with the sketch lock held.
+func (m *model) pinnedItems(items []heap.Item) []legendEntry {
+	out := make([]legendEntry, 0, len(m.pins))
+	for _, pn := range m.pins {
+		e := legendEntry{color: pn.color, out: true}
+		for _, item := range items {
+			if item.Item == pn.item {
+				e.Item, e.out = item, false
+				break
+			}
+		}
+		if e.out {
+			e.Item = heap.Item{
+				Item:        pn.item,
+				Fingerprint: topk.Fingerprint(pn.item),
+				Count:       m.sketch.Count(pn.item),
+			}
+		}
+		out = append(out, e)
+	}
+	return out
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	tui "github.com/charmbracelet/bubbletea"
+	plot "github.com/chriskim06/drawille-go"
+)
+
+// Settings that can be changed without touching the sketch.
+var liveFlags = map[string]bool{
+	"plot-fps":        true,
+	"items-fps":       true,
+	"item-counts-fps": true,
+	"view-split":      true,
+	"search":          true,
+	"log-scale":       true,
+	"track-selected":  true,
+	"stats":           true,
+	"full-refresh":    true,
+	"partial-size":    true,
+	"normalize":       true,
+	"normalize-file":  true,
+	"replay-speed":    true,
+	"replay-jump":     true,
+	"sort":            true,
+	"chart":           true,
+	"trend-ticks":     true,
+	"rise-threshold":  true,
+	"scrub-history":   true,
+	"alert":           true,
+	"alert-sink":      true,
+	"alert-cooldown":  true,
+}
+
+// Settings that are applied by rebuilding the sketch, which drops the window.
+var rebuildFlags = map[string]bool{
+	"k":              true,
+	"width":          true,
+	"depth":          true,
+	"decay":          true,
+	"decay-lut-size": true,
+	"memory-budget":  true,
+	"target-error":   true,
+	"bucket-history": true,
+}
+
+const configPollInterval = time.Second
+
+type configReloadMsg struct {
+	config Config
+	err    error
+}
+
+// watchConfigCmd waits for SIGHUP or a change to the -config file and then
+// reloads the configuration from the original command line.
+func (m *model) watchConfigCmd() tui.Cmd {
+	return func() tui.Msg {
+		ticker := time.NewTicker(configPollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-m.done:
+				return nil
+			case <-m.reloadSignals:
+			case <-ticker.C:
+				if !m.configFileChanged() {
+					continue
+				}
+			}
+			fs := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+			fs.SetOutput(io.Discard)
+			c, err := loadConfig(fs, os.Args[1:])
+			return configReloadMsg{config: c, err: err}
+		}
+	}
+}
+
+// configFileChanged reports whether the -config file's modification time
+// changed since the last call.
+func (m *model) configFileChanged() bool {
+	if config.ConfigPath == "" {
+		return false
+	}
+	info, err := os.Stat(config.ConfigPath)
+	if err != nil {
+		return false
+	}
+	mod := info.ModTime()
+	if m.configModTime.IsZero() {
+		m.configModTime = mod
+		return false
+	}
+	if mod.Equal(m.configModTime) {
+		return false
+	}
+	m.configModTime = mod
+	return true
+}
+
+func notifyReload() chan os.Signal {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGHUP)
+	return ch
+}
+
+// applyConfig switches to c. Render settings take effect immediately, sketch
+// settings rebuild the sketch, and anything else is reported as needing a
+// restart.
+func (m *model) applyConfig(c Config) tui.Cmd {
+	var live, rebuild, restart []string
+	oldValues, newValues := flagValues(&config), flagValues(&c)
+	for name, v := range newValues {
+		if oldValues[name] == v {
+			continue
+		}
+		switch {
+		case liveFlags[name]:
+			live = append(live, name)
+		case rebuildFlags[name]:
+			rebuild = append(rebuild, name)
+		default:
+			restart = append(restart, name)
+		}
+	}
+
+	// Only a changed setting overrides what was toggled at runtime.
+	if c.LogScale != config.LogScale {
+		m.logScale.Store(c.LogScale)
+	}
+	if c.Sort != config.Sort {
+		m.sortBy = c.Sort
+	}
+	if c.Chart != config.Chart {
+		m.chartMode = c.Chart
+	}
+	if c.TrackSelected != config.TrackSelected {
+		m.mu.Lock()
+		m.track = c.TrackSelected
+		m.mu.Unlock()
+	}
+	norm, _ := newNormalizer(c.Normalize)
+	m.normalizer.Store(norm)
+	if c.ReplaySpeed != config.ReplaySpeed {
+		m.replay.setSpeed(c.ReplaySpeed)
+	}
+	var alertErr error
+	if alertsChanged(&config, &c) {
+		if alerts, err := newAlerter(c.Alerts, c.AlertSinks, c.AlertCooldown, m.alerts, m.alertError); err != nil {
+			alertErr = err
+		} else {
+			// Closing waits for queued alerts to be delivered.
+			go m.alerts.close()
+			m.alerts = alerts
+			config.Alerts = c.Alerts
+			config.AlertSinks = c.AlertSinks
+			config.AlertCooldown = c.AlertCooldown
+		}
+	}
+	m.list.SetFilteringEnabled(c.SearchEnabled)
+	m.metrics.setEnabled(c.StatsEnabled)
+	if len(rebuild) > 0 || c.FullRefresh != config.FullRefresh || c.PartialSize != config.PartialSize {
+		// Keep the incremental ranking unless its settings or the sketch change.
+		m.ranker = NewIncrementalRanker(c.K, c.FullRefresh, c.PartialSize)
+	}
+
+	config.PlotFPS = c.PlotFPS
+	config.ItemsFPS = c.ItemsFPS
+	config.ItemCountsFPS = c.ItemCountsFPS
+	config.ViewSplit = c.ViewSplit
+	config.SearchEnabled = c.SearchEnabled
+	config.LogScale = c.LogScale
+	config.TrackSelected = c.TrackSelected
+	config.StatsEnabled = c.StatsEnabled
+	config.FullRefresh = c.FullRefresh
+	config.PartialSize = c.PartialSize
+	config.Normalize = c.Normalize
+	config.NormalizeFile = c.NormalizeFile
+	config.ReplaySpeed = c.ReplaySpeed
+	config.ReplayJump = c.ReplayJump
+	config.Sort = c.Sort
+	config.Chart = c.Chart
+	config.TrendTicks = c.TrendTicks
+	config.RiseThreshold = c.RiseThreshold
+	config.ScrubHistory = c.ScrubHistory
+
+	// The item counts tick stops itself when disabled; restart it if needed.
+	var cmd tui.Cmd
+	if !m.itemCountsTicking {
+		cmd = doItemCountsTick()
+		m.itemCountsTicking = cmd != nil
+	}
+
+	if len(rebuild) > 0 {
+		config.K = c.K
+		config.Width = c.Width
+		config.Depth = c.Depth
+		config.Decay = c.Decay
+		config.DecayLUTSize = c.DecayLUTSize
+		config.MemoryBudget = c.MemoryBudget
+		config.TargetError = c.TargetError
+		config.HistoryLength = c.HistoryLength
+		m.rebuildSketch()
+	}
+	m.layout()
+
+	var notes []string
+	if len(live) > 0 {
+		notes = append(notes, "applied "+joinFlags(live))
+	}
+	if len(rebuild) > 0 {
+		notes = append(notes, "WARNING: sketch rebuilt for "+joinFlags(rebuild)+", window history was reset")
+	}
+	if len(restart) > 0 {
+		notes = append(notes, "restart required for "+joinFlags(restart))
+	}
+	if alertErr != nil {
+		notes = append(notes, "alerts not changed: "+alertErr.Error())
+	}
+	if len(notes) == 0 {
+		notes = append(notes, "no changes")
+	}
+	m.setNotice("config reloaded: " + strings.Join(notes, "; "))
+	return cmd
+}
+
+// rebuildSketch replaces the sketch with an empty one built from config.
+func (m *model) rebuildSketch() {
+	sketch := newSketch(&config)
+	m.sketchMu.Lock()
+	m.sketch = sketch
+	m.total.reset()
+	if m.verifier != nil {
+		m.verifier.exact.reset()
+	}
+	m.sketchMu.Unlock()
+
+	m.mu.Lock()
+	m.listItems = nil
+	m.mu.Unlock()
+	// Snapshots don't line up with a different bucket history.
+	m.history = leaderboardHistory{}
+	m.plotData = make([][]float64, config.K+1+len(pinPalette))
+	for i := range m.plotData {
+		m.plotData[i] = make([]float64, sketch.BucketHistoryLength)
+	}
+	m.plotLineColors = make([]plot.Color, config.K+1+len(pinPalette))
+	m.plot.LineColors = make([]plot.Color, config.K+1+len(pinPalette))
+	m.plot.Fill(m.plotData)
+	m.list.SetItems(nil)
+}
+
+// noticeTimeout is how long a notice stays up unless a key dismisses it.
+const noticeTimeout = 10 * time.Second
+
+// setNotice shows s below the panes for noticeTimeout; "" clears the notice.
+func (m *model) setNotice(s string) {
+	m.mu.Lock()
+	m.notice = s
+	m.noticeUntil = time.Now().Add(noticeTimeout)
+	m.mu.Unlock()
+}
+
+// currentNotice returns the notice if it has not timed out, or "".
+func (m *model) currentNotice() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if time.Now().After(m.noticeUntil) {
+		return ""
+	}
+	return m.notice
+}
+
+// flagValues returns the string form of every flag for c, keyed by name.
+func flagValues(c *Config) map[string]string {
+	cc := *c
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	registerFlags(fs, &cc)
+	values := make(map[string]string)
+	fs.VisitAll(func(f *flag.Flag) { values[f.Name] = f.Value.String() })
+	return values
+}
+
+func joinFlags(names []string) string {
+	sort.Strings(names)
+	for i, name := range names {
+		names[i] = "-" + name
+	}
+	return strings.Join(names, ", ")
+}
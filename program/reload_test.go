@@ -0,0 +1,37 @@
+package main
+
+import "testing"
+
+func TestApplyConfigKeepsToggles(t *testing.T) {
+	setConfig(t, func(c *Config) {
+		c.Sort = sortByCount
+		c.Chart = chartLines
+		c.LogScale = false
+		c.TrackSelected = false
+	})
+	m := newModel(newSketch(&config))
+	m.width, m.height = 100, 30
+	m.layout()
+	m.toggleSort()
+	m.cycleChart()
+	m.toggleScale()
+	m.toggleTracking()
+
+	// Reloading the same settings keeps what was toggled.
+	m.applyConfig(config)
+	if m.sortBy != sortByRise || m.chartMode != chartStacked || !m.logScale.Load() || !m.track {
+		t.Errorf("after reloading the same config: sort %s, chart %s, log scale %v, tracking %v; want the toggled rise, stacked, true, true",
+			m.sortBy, m.chartMode, m.logScale.Load(), m.track)
+	}
+
+	// A changed setting overrides the toggle; the others stay.
+	c := config
+	c.Chart = chartBars
+	m.applyConfig(c)
+	if m.chartMode != chartBars {
+		t.Errorf("chart %s after -chart changed to %s", m.chartMode, chartBars)
+	}
+	if m.sortBy != sortByRise {
+		t.Errorf("sort %s after reloading an unchanged -sort, want the toggled %s", m.sortBy, sortByRise)
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	minReplaySpeed = 1.0 / 64
+	maxReplaySpeed = 1 << 20
+)
+
+// replayControl is the replay state changed by key bindings while the input
+// is being read. Readers consult it instead of config.ReplaySpeed.
+type replayControl struct {
+	mu      sync.Mutex
+	speed   float64
+	changed chan struct{} // closed and replaced on every change
+
+	position atomic.Int64 // event time of the last record, UnixNano
+
+	// Guarded by the model's pauseMu, next to paused.
+	steps     int       // ticks left to advance before pausing again
+	skipUntil time.Time // fast-forward (no replay sleeps) to this event time
+
+	refresh atomic.Bool // a step ended while paused; refresh the leaderboard once
+}
+
+func newReplayControl(speed float64) *replayControl {
+	return &replayControl{speed: speed, changed: make(chan struct{})}
+}
+
+func (r *replayControl) state() (speed float64, changed <-chan struct{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.speed, r.changed
+}
+
+func (r *replayControl) setSpeed(speed float64) {
+	r.mu.Lock()
+	r.speed = min(maxReplaySpeed, max(minReplaySpeed, speed))
+	r.mu.Unlock()
+	r.notify()
+}
+
+func (r *replayControl) scaleSpeed(factor float64) {
+	speed, _ := r.state()
+	r.setSpeed(speed * factor)
+}
+
+// notify wakes a reader sleeping between records so it picks up the change.
+func (r *replayControl) notify() {
+	r.mu.Lock()
+	close(r.changed)
+	r.changed = make(chan struct{})
+	r.mu.Unlock()
+}
+
+func (r *replayControl) positionTime() time.Time {
+	ns := r.position.Load()
+	if ns == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ns)
+}
+
+// step advances event time by one tick, pausing again afterwards.
+func (m *model) step() {
+	if !m.timestampsFromData.Load() {
+		return
+	}
+	m.pauseMu.Lock()
+	m.paused = true
+	m.replay.steps++
+	m.pauseMu.Unlock()
+	m.pauseCond.Broadcast()
+	m.replay.notify()
+}
+
+// jump fast-forwards event time by config.ReplayJump. When paused, the
+// reader pauses again once it gets there.
+func (m *model) jump() {
+	if !m.timestampsFromData.Load() {
+		return
+	}
+	m.pauseMu.Lock()
+	if m.replay.skipUntil.IsZero() {
+		m.replay.skipUntil = m.replay.positionTime()
+	}
+	m.replay.skipUntil = m.replay.skipUntil.Add(config.ReplayJump)
+	m.pauseMu.Unlock()
+	m.pauseCond.Broadcast()
+	m.replay.notify()
+}
+
+// fastForwarding reports whether a step or jump is in progress.
+func (m *model) fastForwarding() bool {
+	m.pauseMu.Lock()
+	defer m.pauseMu.Unlock()
+	return m.replay.steps > 0 || !m.replay.skipUntil.IsZero()
+}
+
+// reachedTick is called by a reader when event time crosses into tick t. It
+// ends a step or jump that is complete and then holds the reader if paused,
+// so that the finished bucket is shown before the next one starts.
+func (m *model) reachedTick(t time.Time) {
+	m.pauseMu.Lock()
+	done := false
+	if m.replay.steps > 0 {
+		m.replay.steps--
+		done = m.replay.steps == 0
+	}
+	if !m.replay.skipUntil.IsZero() && !t.Before(m.replay.skipUntil.Truncate(config.TickSize)) {
+		m.replay.skipUntil = time.Time{}
+		done = true
+	}
+	m.pauseMu.Unlock()
+	if done {
+		m.replay.refresh.Store(true)
+		m.waitIfPaused()
+	}
+}
+
+// replayWait sleeps for an event-time gap scaled by the current replay
+// speed. Speed changes apply to the remainder of the gap, and steps and jumps
+// skip it.
+func (m *model) replayWait(gap time.Duration) {
+	for gap > 0 && !m.fastForwarding() {
+		speed, changed := m.replay.state()
+		sleep := time.Duration(float64(gap) / speed)
+		capped := config.ReplayMaxSleep > 0 && sleep > config.ReplayMaxSleep
+		if capped {
+			sleep = config.ReplayMaxSleep
+		}
+		start := time.Now()
+		timer := time.NewTimer(sleep)
+		select {
+		case <-timer.C:
+			return
+		case <-m.done:
+			timer.Stop()
+			return
+		case <-changed:
+			timer.Stop()
+			if capped {
+				return
+			}
+			gap -= time.Duration(float64(time.Since(start)) * speed)
+		}
+	}
+}
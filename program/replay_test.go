@@ -0,0 +1,31 @@
+package main
+
+import (
+	"testing"
+
+	tui "github.com/charmbracelet/bubbletea"
+)
+
+func TestReplayKeysOnlyInReplay(t *testing.T) {
+	for _, replay := range []bool{false, true} {
+		setConfig(t, func(c *Config) {
+			c.Replay = replay
+			c.ReplaySpeed = 1
+		})
+		m := newModel(newSketch(&config))
+		m.timestampsFromData.Store(true)
+		m.Update(tui.KeyMsg{Type: tui.KeyRunes, Runes: []rune("+")})
+		m.Update(tui.KeyMsg{Type: tui.KeyRunes, Runes: []rune(".")})
+		speed, _ := m.replay.state()
+		want := 1.0
+		if replay {
+			want = 2
+		}
+		if speed != want {
+			t.Errorf("replay %v: speed %v after +, want %v", replay, speed, want)
+		}
+		if stepping := m.paused && m.replay.steps == 1; stepping != replay {
+			t.Errorf("replay %v: stepping %v after .", replay, stepping)
+		}
+	}
+}
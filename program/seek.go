@@ -0,0 +1,271 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// timeBoundLayouts are accepted by -replay-from and -replay-until besides
+// RFC 3339. Times without a zone are in the zone of the input's timestamps,
+// and a bare clock time means its first occurrence at or after the input's
+// first record.
+var timeBoundLayouts = []string{
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04",
+}
+
+var clockBoundLayouts = []string{"15:04:05", "15:04"}
+
+// parseTimeBound parses a -replay-from/-replay-until value relative to ref,
+// the input's first event time.
+func parseTimeBound(s string, ref time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	for _, layout := range timeBoundLayouts {
+		if t, err := time.ParseInLocation(layout, s, ref.Location()); err == nil {
+			return t, nil
+		}
+	}
+	for _, layout := range clockBoundLayouts {
+		c, err := time.Parse(layout, s)
+		if err != nil {
+			continue
+		}
+		y, mo, d := ref.Date()
+		t := time.Date(y, mo, d, c.Hour(), c.Minute(), c.Second(), 0, ref.Location())
+		if t.Before(ref) {
+			t = t.AddDate(0, 0, 1)
+		}
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q (use RFC 3339, 2006-01-02 15:04:05 or 15:04)", s)
+}
+
+// timeRange limits a timestamped input to [from, until). Records from one
+// window before from are still counted, so that the leaderboard is complete
+// at from; they are fast-forwarded rather than replayed.
+type timeRange struct {
+	from, until time.Time
+	warmFrom    time.Time
+	resolved    bool
+}
+
+func newTimeRange() *timeRange {
+	if config.ReplayFrom == "" && config.ReplayUntil == "" {
+		return nil
+	}
+	return &timeRange{}
+}
+
+// resolve fixes the bounds relative to the input's first event time.
+func (r *timeRange) resolve(ref time.Time) {
+	r.resolved = true
+	if config.ReplayFrom != "" {
+		r.from, _ = parseTimeBound(config.ReplayFrom, ref)
+		r.warmFrom = r.from.Add(-config.WindowSize)
+	}
+	if config.ReplayUntil != "" {
+		r.until, _ = parseTimeBound(config.ReplayUntil, ref)
+	}
+}
+
+// inTimeRange reports whether a record at t comes before the warm-up window
+// and should be skipped, or at or after -replay-until and ends the input.
+func (m *model) inTimeRange(t time.Time) (skip, stop bool) {
+	r := m.timeRange
+	if r == nil || t.IsZero() {
+		return false, false
+	}
+	if !r.resolved {
+		r.resolve(t)
+		m.startWarmUp(r)
+	}
+	if !r.until.IsZero() && !t.Before(r.until) {
+		return false, true
+	}
+	return !r.warmFrom.IsZero() && t.Before(r.warmFrom), false
+}
+
+// seekInput positions a seekable input at the start of the warm-up window
+// for -replay-from, so that the records before it are never read. Inputs it
+// can't search, such as JSON spread over several lines, are read from the
+// start and skipped up to the warm-up window instead.
+func (m *model) seekInput(r io.Reader) error {
+	rng := m.timeRange
+	if rng == nil || config.ReplayFrom == "" {
+		return nil
+	}
+	f, ok := r.(io.ReadSeeker)
+	if !ok {
+		return nil
+	}
+	// Access logs may hold lines that aren't records, but a JSON line that
+	// isn't one means records span several lines.
+	eventTime, strict := accessLogEventTime, false
+	if config.JSON {
+		eventTime, strict = jsonLineEventTime, true
+	}
+	first, err := firstEventTime(f, eventTime, strict)
+	if err != nil && err != errNotLineRecords {
+		return err
+	}
+	off := int64(0)
+	if !first.IsZero() {
+		rng.resolve(first)
+		m.startWarmUp(rng)
+		if off, err = seekEventTime(f, rng.warmFrom, eventTime, strict); err == errNotLineRecords {
+			off = 0
+		} else if err != nil {
+			return err
+		}
+	}
+	_, err = f.Seek(off, io.SeekStart)
+	return err
+}
+
+// startWarmUp makes the reader fast-forward through the warm-up window to
+// -replay-from; a paused reader then stops there.
+func (m *model) startWarmUp(rng *timeRange) {
+	if rng.from.IsZero() {
+		return
+	}
+	m.pauseMu.Lock()
+	m.replay.skipUntil = rng.from
+	m.pauseMu.Unlock()
+}
+
+func accessLogEventTime(line []byte) (time.Time, bool) {
+	_, ts, ok := parseAccessLogLine(string(line))
+	if !ok {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(config.TimestampLayout, ts)
+	return t, err == nil
+}
+
+// jsonLineEventTime reads the timestamp of a JSON record on a single line.
+func jsonLineEventTime(line []byte) (time.Time, bool) {
+	var rec jsonRecord
+	if err := json.Unmarshal(line, &rec); err != nil {
+		return time.Time{}, false
+	}
+	t := rec.eventTime(config.TimestampLayout)
+	return t, !t.IsZero()
+}
+
+// errNotLineRecords reports a line that isn't a record where every line
+// should be one.
+var errNotLineRecords = errors.New("input is not one record per line")
+
+// firstEventTime returns the time of the first timestamped line, or zero if
+// there is none.
+func firstEventTime(f io.ReadSeeker, eventTime func([]byte) (time.Time, bool), strict bool) (time.Time, error) {
+	_, first, err := probeEventTime(f, 0, eventTime, strict)
+	return first, err
+}
+
+// probeEventTime returns the offset and time of the first timestamped line
+// starting at or after off; the time is zero if there is none. If strict,
+// other non-blank lines are errNotLineRecords.
+func probeEventTime(f io.ReadSeeker, off int64, eventTime func([]byte) (time.Time, bool), strict bool) (int64, time.Time, error) {
+	var t time.Time
+	var bad bool
+	err := scanLinesFrom(f, off, func(start int64, line []byte) bool {
+		lt, ok := eventTime(line)
+		if ok {
+			off, t = start, lt
+			return false
+		}
+		bad = strict && len(bytes.TrimSpace(line)) > 0
+		return !bad
+	})
+	if err == nil && bad {
+		err = errNotLineRecords
+	}
+	return off, t, err
+}
+
+// seekMinSpan is where the binary search hands over to a linear scan.
+const seekMinSpan = 64 << 10
+
+// seekEventTime binary-searches a file sorted by event time for the offset
+// of the first line at or after target. If strict, a line it reads that isn't
+// timestamped is errNotLineRecords.
+func seekEventTime(f io.ReadSeeker, target time.Time, eventTime func([]byte) (time.Time, bool), strict bool) (int64, error) {
+	size, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, err
+	}
+	// The first line at or after target starts in [lo, hi].
+	lo, hi := int64(0), size
+	for hi-lo > seekMinSpan {
+		mid := lo + (hi-lo)/2
+		_, t, err := probeEventTime(f, mid, eventTime, strict)
+		if err != nil {
+			return 0, err
+		}
+		if t.IsZero() || !t.Before(target) {
+			hi = mid
+		} else {
+			lo = mid
+		}
+	}
+	off := size
+	var bad bool
+	err = scanLinesFrom(f, lo, func(start int64, line []byte) bool {
+		t, ok := eventTime(line)
+		if !ok {
+			bad = strict && len(bytes.TrimSpace(line)) > 0
+			return !bad
+		}
+		if !t.Before(target) {
+			off = start
+			return false
+		}
+		return true
+	})
+	if err == nil && bad {
+		err = errNotLineRecords
+	}
+	return off, err
+}
+
+// scanLinesFrom calls fn for each line starting at or after off, until fn
+// returns false or the input ends.
+func scanLinesFrom(f io.ReadSeeker, off int64, fn func(start int64, line []byte) bool) error {
+	start := max(0, off-1)
+	if _, err := f.Seek(start, io.SeekStart); err != nil {
+		return err
+	}
+	br := bufio.NewReader(f)
+	if off > 0 {
+		// Skip to the line that begins at or after off.
+		skipped, err := br.ReadBytes('\n')
+		start += int64(len(skipped))
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(line) > 0 && !fn(start, line) {
+			return nil
+		}
+		start += int64(len(line))
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// unixLineTime reads the event time of test lines "<unix seconds> <item>";
+// other lines have none.
+func unixLineTime(line []byte) (time.Time, bool) {
+	sec, _, ok := strings.Cut(string(line), " ")
+	if !ok {
+		return time.Time{}, false
+	}
+	n, err := strconv.ParseInt(sec, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(n, 0), true
+}
+
+// timedLines returns a file with one line per element of secs, and a line
+// without a time after every tenth, and the offset of each line.
+func timedLines(secs []int64) ([]byte, []int64) {
+	var buf bytes.Buffer
+	offsets := make([]int64, len(secs))
+	for i, sec := range secs {
+		offsets[i] = int64(buf.Len())
+		fmt.Fprintf(&buf, "%d item-%d\n", sec, i)
+		if i%10 == 9 {
+			buf.WriteString("- no time here\n")
+		}
+	}
+	return buf.Bytes(), offsets
+}
+
+func TestSeekEventTime(t *testing.T) {
+	// Large enough for several rounds of binary search before the scan.
+	const n = 20000
+	secs := make([]int64, n)
+	for i := range secs {
+		// Three records per second.
+		secs[i] = 1000 + int64(i/3)
+	}
+	large, largeOffsets := timedLines(secs)
+	if len(large) < 4*seekMinSpan {
+		t.Fatalf("test input is only %d bytes", len(large))
+	}
+	small, smallOffsets := timedLines([]int64{10, 20, 20, 30})
+
+	tests := []struct {
+		name   string
+		data   []byte
+		target int64
+		want   int64
+	}{
+		{"small, before the first line", small, 5, 0},
+		{"small, at the first line", small, 10, smallOffsets[0]},
+		{"small, first of equal times", small, 20, smallOffsets[1]},
+		{"small, between lines", small, 25, smallOffsets[3]},
+		{"small, after the last line", small, 31, int64(len(small))},
+		{"large, before the first line", large, 0, 0},
+		{"large, first of equal times", large, 1000 + n/6, largeOffsets[n/6*3]},
+		{"large, near the start", large, 1001, largeOffsets[3]},
+		{"large, near the end", large, 1000 + (n-1)/3, largeOffsets[(n-1)/3*3]},
+		{"large, after the last line", large, 1000 + n, int64(len(large))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := seekEventTime(bytes.NewReader(tt.data), time.Unix(tt.target, 0), unixLineTime, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("seekEventTime(%d) = %d, want %d", tt.target, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSeekEventTimeMatchesScan(t *testing.T) {
+	// Timestamps with gaps, so that targets fall between records.
+	secs := make([]int64, 30000)
+	for i := range secs {
+		secs[i] = int64(i * i / 1000)
+	}
+	data, offsets := timedLines(secs)
+	for target := int64(-1); target <= secs[len(secs)-1]+1; target += 7919 {
+		want := int64(len(data))
+		for i, sec := range secs {
+			if sec >= target {
+				want = offsets[i]
+				break
+			}
+		}
+		got, err := seekEventTime(bytes.NewReader(data), time.Unix(target, 0), unixLineTime, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("seekEventTime(%d) = %d, want %d", target, got, want)
+		}
+	}
+}
+
+func TestParseTimeBound(t *testing.T) {
+	zone := time.FixedZone("", -7*3600)
+	ref := time.Date(2019, 1, 22, 14, 0, 0, 0, zone)
+	tests := []struct {
+		s    string
+		want time.Time
+	}{
+		{"2019-01-22T15:00:00Z", time.Date(2019, 1, 22, 15, 0, 0, 0, time.UTC)},
+		{"2019-01-23 08:30:15", time.Date(2019, 1, 23, 8, 30, 15, 0, zone)},
+		{"2019-01-23T08:30", time.Date(2019, 1, 23, 8, 30, 0, 0, zone)},
+		{"14:32", time.Date(2019, 1, 22, 14, 32, 0, 0, zone)},
+		{"14:00", ref},
+		// Before the first record: the next day.
+		{"13:59:59", time.Date(2019, 1, 23, 13, 59, 59, 0, zone)},
+	}
+	for _, tt := range tests {
+		got, err := parseTimeBound(tt.s, ref)
+		if err != nil {
+			t.Errorf("parseTimeBound(%q): %v", tt.s, err)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("parseTimeBound(%q) = %s, want %s", tt.s, got, tt.want)
+		}
+	}
+	if _, err := parseTimeBound("yesterday", ref); err == nil {
+		t.Error("parseTimeBound(\"yesterday\") succeeded")
+	}
+}
+
+func TestSeekInputJSON(t *testing.T) {
+	start := time.Date(2024, 1, 22, 3, 0, 0, 0, time.UTC)
+	const n = 6000
+	// Records one second apart; each layout writes record i starting at
+	// offsets[i].
+	layouts := []struct {
+		name      string
+		multiLine func(i int) bool
+		want      func(offsets []int64, i int) int64 // where the seek ends for a target at record i
+	}{
+		{"one record per line", func(int) bool { return false }, func(offsets []int64, i int) int64 { return offsets[i] }},
+		{"multi-line", func(int) bool { return true }, func([]int64, int) int64 { return 0 }},
+		{"multi-line after the first records", func(i int) bool { return i >= 10 }, func([]int64, int) int64 { return 0 }},
+		// The search never reads the tail, and the seek still lands on a record.
+		{"multi-line at the end", func(i int) bool { return i >= n-10 }, func(offsets []int64, i int) int64 { return offsets[i] }},
+	}
+	for _, l := range layouts {
+		t.Run(l.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			offsets := make([]int64, n)
+			for i := range n {
+				offsets[i] = int64(buf.Len())
+				ts := start.Add(time.Duration(i) * time.Second).Unix()
+				if l.multiLine(i) {
+					fmt.Fprintf(&buf, "{\n  \"item\": \"item-%d\",\n  \"timestamp\": %d\n}\n", i%7, ts)
+				} else {
+					fmt.Fprintf(&buf, "{\"item\": \"item-%d\", \"timestamp\": %d}\n", i%7, ts)
+				}
+			}
+			if buf.Len() < 4*seekMinSpan {
+				t.Fatalf("test input is only %d bytes", buf.Len())
+			}
+			const target = n / 2
+			setConfig(t, func(c *Config) {
+				c.JSON = true
+				c.WindowSize = 10 * time.Second
+				c.ReplayFrom = start.Add((target + 10) * time.Second).Format(time.RFC3339)
+				c.ReplayUntil = ""
+			})
+			m := newModel(newSketch(&config))
+			m.timeRange = newTimeRange()
+			r := bytes.NewReader(buf.Bytes())
+			if err := m.seekInput(r); err != nil {
+				t.Fatal(err)
+			}
+			got, _ := r.Seek(0, io.SeekCurrent)
+			if want := l.want(offsets, target); got != want {
+				t.Errorf("seekInput left the input at %d, want %d", got, want)
+			}
+		})
+	}
+}
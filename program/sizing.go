@@ -0,0 +1,304 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"hash/maphash"
+	"io"
+	"math"
+	"math/bits"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unsafe"
+
+	"github.com/keilerkonzept/topk/sliding"
+)
+
+// byteSize is a flag value for sizes such as 64MB, 512KiB or 1048576.
+type byteSize int64
+
+func (b *byteSize) String() string { return formatBytes(int64(*b)) }
+
+func (b *byteSize) Set(v string) error {
+	s := strings.ToUpper(strings.TrimSpace(v))
+	units := []struct {
+		suffix string
+		mult   int64
+	}{
+		{"KIB", 1 << 10}, {"MIB", 1 << 20}, {"GIB", 1 << 30},
+		{"KB", 1e3}, {"MB", 1e6}, {"GB", 1e9},
+		{"K", 1 << 10}, {"M", 1 << 20}, {"G", 1 << 30},
+		{"B", 1},
+	}
+	mult := int64(1)
+	for _, u := range units {
+		if rest, ok := strings.CutSuffix(s, u.suffix); ok {
+			s, mult = strings.TrimSpace(rest), u.mult
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil || n < 0 {
+		return fmt.Errorf("invalid size %q", v)
+	}
+	*b = byteSize(n * float64(mult))
+	return nil
+}
+
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.1fGiB", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1fMiB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1fKiB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%dB", n)
+}
+
+const (
+	sizeofBucket = int64(unsafe.Sizeof(sliding.Bucket{}))
+	// Rough per-item allowance for the heap's stored keys and index entries.
+	heapKeyReserve = 96
+	// Narrowest width worth trading bucket history for.
+	minAutoWidth = 256
+	// Widest row -target-error may ask for; at depth 3 with one tick of
+	// history its buckets already take 2GiB.
+	maxTargetWidth = 1 << 24
+	// Past a few buckets per distinct item, a wider row only adds empty
+	// buckets.
+	widthPerItem = 4
+	// Derived depths: enough rows that all K estimates hold the bound with
+	// probability 1-topKFailure, within these limits.
+	minAutoDepth = 3
+	maxAutoDepth = 8
+	topKFailure  = 0.05
+)
+
+// sizeSketch derives the sketch width, depth and bucket history length from
+// -memory-budget and/or -target-error, K, the window/tick ratio and the
+// cardinality of the input. An explicit -depth is kept; an explicit -width is
+// kept with a budget and rejected with -target-error, which derives it.
+//
+// The sizing follows the Count-Min analysis: with width w and depth d, an
+// estimate overshoots by more than e/w of the window total with probability
+// at most e^-d, so all K estimates hold with probability 1-K·e^-d. The sketch
+// is a HeavyKeeper, whose decay mostly makes estimates undercount, so the
+// bound is a guide for sizing rather than a guarantee.
+//
+// -target-error fixes w = e/target; a memory budget then limits how much
+// per-bucket history is affordable. With only a budget, the widest sketch with
+// full per-tick history is used, but no wider than widthPerItem times the
+// distinct items of a window of the input (see sampleCardinality), falling
+// back to coarser history when that would be narrower than minAutoWidth.
+func sizeSketch(c *Config) error {
+	if c.MemoryBudget == 0 && c.TargetError == 0 {
+		return nil
+	}
+	if c.explicit["width"] && c.TargetError > 0 {
+		return flagErrorf("width", "cannot be combined with -target-error, which derives it")
+	}
+	ticks := int(c.WindowSize / c.TickSize)
+	history := ticks
+	if c.HistoryLength > 0 {
+		history = min(ticks, c.HistoryLength)
+	}
+
+	if !c.explicit["depth"] {
+		c.Depth = depthForK(c.K)
+	}
+	depth := int64(c.Depth)
+
+	width := int64(0)
+	switch {
+	case c.explicit["width"]:
+		width = int64(c.Width)
+	case c.TargetError > 0:
+		w := math.Ceil(math.E / c.TargetError)
+		if w > maxTargetWidth {
+			size := min(w*float64(depth*perBucketBytes(history)), 1<<62)
+			return flagErrorf("target-error", "%g needs a sketch %.0f wide, %s for the buckets alone; at most %d is supported (raise -target-error)", c.TargetError, w, formatBytes(int64(size)), maxTargetWidth)
+		}
+		width = int64(w)
+	}
+	if c.MemoryBudget > 0 {
+		avail := int64(c.MemoryBudget) - fixedSketchBytes(c)
+		if avail <= 0 {
+			return flagErrorf("memory-budget", "%s is too small for -k %d", formatBytes(int64(c.MemoryBudget)), c.K)
+		}
+		if width == 0 {
+			width = avail / (depth * perBucketBytes(history))
+			if items, ok := sampleCardinality(c); ok {
+				width = min(width, max(minAutoWidth, widthPerItem*int64(items)))
+			}
+			if width < minAutoWidth && c.HistoryLength == 0 {
+				width = minAutoWidth
+				history = int((avail/(depth*width) - sizeofBucket) / 4)
+			}
+		} else if cost := width * depth * perBucketBytes(history); cost > avail {
+			history = int((avail/(depth*width) - sizeofBucket) / 4)
+		}
+		if width < 1 || history < 1 {
+			return flagErrorf("memory-budget", "%s cannot fit a %d-deep sketch (raise the budget, or lower -depth, -k or -target-error)", formatBytes(int64(c.MemoryBudget)), c.Depth)
+		}
+	}
+	c.Width = int(width)
+	c.HistoryLength = history
+	return nil
+}
+
+// fixedSketchBytes estimates the part of the sketch size that does not depend
+// on the width: the struct, the decay table, and the heap.
+func fixedSketchBytes(c *Config) int64 {
+	s := sliding.New(c.K, int(c.WindowSize/c.TickSize),
+		sliding.WithWidth(1),
+		sliding.WithDepth(1),
+		sliding.WithBucketHistoryLength(1),
+		sliding.WithDecayLUTSize(c.DecayLUTSize),
+	)
+	return int64(s.SizeBytes()) - perBucketBytes(1) + int64(c.K)*heapKeyReserve
+}
+
+func perBucketBytes(history int) int64 { return sizeofBucket + 4*int64(history) }
+
+// depthForK returns the depth at which the union bound K·e^-d over all K
+// estimates stays under topKFailure.
+func depthForK(k int) int {
+	d := int(math.Ceil(math.Log(float64(k) / topKFailure)))
+	return min(maxAutoDepth, max(minAutoDepth, d))
+}
+
+// errorBound returns the Count-Min bound for a sketch of this size: estimates
+// exceed the true count by more than epsilon times the window total with
+// probability at most delta. HeavyKeeper estimates only approximately follow
+// it (see sizeSketch).
+func errorBound(width, depth int) (epsilon, delta float64) {
+	return math.E / float64(width), math.Exp(-float64(depth))
+}
+
+// sizingSampleBytes is how much of the input file sampleCardinality reads.
+const sizingSampleBytes = 16 << 20
+
+// sampleCardinality estimates the number of distinct items in one window of
+// the input file from its first sizingSampleBytes, parsed and normalized as
+// c says. It reports false when there is no file, or when the sample neither
+// spans a window of event time nor holds the whole file, since the items of
+// part of a window say little about a full one.
+func sampleCardinality(c *Config) (uint64, bool) {
+	if c.InputPath == "" {
+		return 0, false
+	}
+	f, err := os.Open(c.InputPath)
+	if err != nil {
+		return 0, false
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return 0, false
+	}
+	norm, _ := newNormalizer(c.Normalize)
+	// A fixed hash, so that reloading the same input sizes the same sketch.
+	est := &cardinalityEstimator{hash: stableHash}
+	var first time.Time
+	scanner := bufio.NewScanner(io.LimitReader(f, sizingSampleBytes))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		item, t := scanner.Text(), time.Time{}
+		switch {
+		case c.AccessLog:
+			ip, ts, ok := parseAccessLogLine(item)
+			if !ok {
+				continue
+			}
+			item = ip
+			t, _ = time.Parse(c.TimestampLayout, ts)
+		case c.JSON:
+			var r jsonRecord
+			if json.Unmarshal(scanner.Bytes(), &r) != nil {
+				continue
+			}
+			item, t = r.Item, r.eventTime(c.TimestampLayout)
+		}
+		est.observe(norm.apply(item))
+		if t.IsZero() {
+			continue
+		}
+		if first.IsZero() {
+			first = t
+		} else if t.Sub(first) >= c.WindowSize {
+			return est.estimate(), true
+		}
+	}
+	if scanner.Err() != nil || info.Size() > sizingSampleBytes {
+		return 0, false
+	}
+	return est.estimate(), true
+}
+
+// stableHash is FNV-1a with a final mix, so that the high bits HyperLogLog
+// indexes by are well spread.
+func stableHash(s string) uint64 {
+	h := uint64(14695981039346656037)
+	for i := 0; i < len(s); i++ {
+		h ^= uint64(s[i])
+		h *= 1099511628211
+	}
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
+
+// cardinalityEstimator is a HyperLogLog distinct-item counter over all items
+// seen so far (not just the current window).
+type cardinalityEstimator struct {
+	mu        sync.Mutex
+	hash      func(string) uint64
+	registers [1 << cardinalityPrecision]uint8
+}
+
+const cardinalityPrecision = 12
+
+func newCardinalityEstimator() *cardinalityEstimator {
+	seed := maphash.MakeSeed()
+	return &cardinalityEstimator{hash: func(s string) uint64 { return maphash.String(seed, s) }}
+}
+
+func (e *cardinalityEstimator) observe(item string) {
+	h := e.hash(item)
+	idx := h >> (64 - cardinalityPrecision)
+	rank := uint8(bits.LeadingZeros64(h<<cardinalityPrecision|1<<(cardinalityPrecision-1)) + 1)
+	e.mu.Lock()
+	if rank > e.registers[idx] {
+		e.registers[idx] = rank
+	}
+	e.mu.Unlock()
+}
+
+func (e *cardinalityEstimator) estimate() uint64 {
+	const m = float64(len(e.registers))
+	var sum float64
+	zeros := 0
+	e.mu.Lock()
+	for _, r := range e.registers {
+		sum += 1 / float64(uint64(1)<<r)
+		if r == 0 {
+			zeros++
+		}
+	}
+	e.mu.Unlock()
+	est := 0.7213 / (1 + 1.079/m) * m * m / sum
+	if est <= 2.5*m && zeros > 0 {
+		// Small-range correction (linear counting).
+		est = m * math.Log(m/float64(zeros))
+	}
+	return uint64(est)
+}
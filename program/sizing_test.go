@@ -0,0 +1,302 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDepthForK(t *testing.T) {
+	tests := []struct {
+		k, want int
+	}{
+		{1, 3}, // ln 20 = 3.0
+		{10, 6},
+		{50, 7},
+		{100, 8},
+		{1000, 8}, // capped
+	}
+	for _, tt := range tests {
+		if got := depthForK(tt.k); got != tt.want {
+			t.Errorf("depthForK(%d) = %d, want %d", tt.k, got, tt.want)
+		}
+	}
+}
+
+// writeSample writes lines to a file in a temporary directory and returns
+// its path.
+func writeSample(t *testing.T, lines []string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "input")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestSizeSketch(t *testing.T) {
+	small := writeSample(t, func() []string {
+		lines := make([]string, 1000)
+		for i := range lines {
+			lines[i] = fmt.Sprintf("item-%d", i%100)
+		}
+		return lines
+	}())
+	tests := []struct {
+		name     string
+		set      func(c *Config)
+		explicit []string
+		width    int // 0: the widest that fits -memory-budget
+		depth    int
+		history  int // 0: the longest that fits -memory-budget
+		err      string
+	}{
+		{
+			name:    "neither budget nor target",
+			set:     func(c *Config) {},
+			width:   3000,
+			depth:   3,
+			history: 0,
+		},
+		{
+			name:    "target-error",
+			set:     func(c *Config) { c.TargetError = 0.001 },
+			width:   2719, // e/0.001
+			depth:   6,
+			history: 60,
+		},
+		{
+			name:     "target-error with -depth and -bucket-history",
+			set:      func(c *Config) { c.TargetError, c.Depth, c.HistoryLength = 0.001, 4, 10 },
+			explicit: []string{"depth"},
+			width:    2719,
+			depth:    4,
+			history:  10,
+		},
+		{
+			name:    "narrowest target-error",
+			set:     func(c *Config) { c.TargetError = math.E / (maxTargetWidth - 0.5) },
+			width:   maxTargetWidth,
+			depth:   6,
+			history: 60,
+		},
+		{
+			name: "target-error too small",
+			set:  func(c *Config) { c.TargetError = 1e-9 },
+			err:  "-target-error 1e-09 needs a sketch 2718281829 wide, 4253.1GiB for the buckets alone",
+		},
+		{
+			name:     "target-error and width",
+			set:      func(c *Config) { c.TargetError = 0.001 },
+			explicit: []string{"width"},
+			err:      "-width cannot be combined with -target-error",
+		},
+		{
+			name:    "budget",
+			set:     func(c *Config) { c.MemoryBudget = 64 << 20 },
+			depth:   6,
+			history: 60,
+		},
+		{
+			name:    "budget capped by the sampled items",
+			set:     func(c *Config) { c.MemoryBudget, c.InputPath = 64<<20, small },
+			width:   404, // 4 per item, for an estimate of 101 items
+			depth:   6,
+			history: 60,
+		},
+		{
+			name:     "budget with -width",
+			set:      func(c *Config) { c.MemoryBudget, c.Width = 16<<20, 5000 },
+			explicit: []string{"width"},
+			width:    5000,
+			depth:    6,
+			history:  60,
+		},
+		{
+			name:     "budget with -width, history cut",
+			set:      func(c *Config) { c.MemoryBudget, c.Width = 4<<20, 5000 },
+			explicit: []string{"width"},
+			width:    5000,
+			depth:    6,
+		},
+		{
+			name:  "budget and target-error",
+			set:   func(c *Config) { c.MemoryBudget, c.TargetError = 16<<20, 0.0001 },
+			width: 27183,
+			depth: 6,
+		},
+		{
+			name:  "budget too narrow for per-tick history",
+			set:   func(c *Config) { c.MemoryBudget, c.TickSize = 1<<20, time.Second },
+			width: minAutoWidth,
+			depth: 6,
+		},
+		{
+			name: "budget too small",
+			set:  func(c *Config) { c.MemoryBudget = 1 << 10 },
+			err:  "-memory-budget 1.0KiB is too small for -k 10",
+		},
+		{
+			name:     "budget too small for the width",
+			set:      func(c *Config) { c.MemoryBudget, c.Width = 1<<20, 1<<20 },
+			explicit: []string{"width"},
+			err:      "-memory-budget 1.0MiB cannot fit a 6-deep sketch",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := config
+			c.K = 10
+			c.Width, c.Depth = 3000, 3
+			c.WindowSize, c.TickSize, c.HistoryLength = time.Hour, time.Minute, 0
+			c.MemoryBudget, c.TargetError, c.InputPath = 0, 0, ""
+			c.Normalize = nil
+			tt.set(&c)
+			c.explicit = make(map[string]bool)
+			for _, name := range tt.explicit {
+				c.explicit[name] = true
+			}
+			err := sizeSketch(&c)
+			if tt.err != "" {
+				var fe *flagError
+				if !errors.As(err, &fe) || !strings.HasPrefix(err.Error(), tt.err) {
+					t.Fatalf("error %v, want a flag error starting %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.Depth != tt.depth {
+				t.Errorf("depth = %d, want %d", c.Depth, tt.depth)
+			}
+			if tt.width != 0 && c.Width != tt.width {
+				t.Errorf("width = %d, want %d", c.Width, tt.width)
+			}
+			if tt.history != 0 && c.HistoryLength != tt.history {
+				t.Errorf("bucket history = %d, want %d", c.HistoryLength, tt.history)
+			}
+			if c.MemoryBudget == 0 {
+				return
+			}
+			// What the budget has room for, and no more.
+			budget := int64(c.MemoryBudget)
+			size := func(width, history int) int64 {
+				return fixedSketchBytes(&c) + int64(width*c.Depth)*perBucketBytes(history)
+			}
+			if size(c.Width, c.HistoryLength) > budget {
+				t.Errorf("a %dx%d sketch with %d buckets of history takes %s, over the %s budget",
+					c.Width, c.Depth, c.HistoryLength, formatBytes(size(c.Width, c.HistoryLength)), formatBytes(budget))
+			}
+			ticks := int(c.WindowSize / c.TickSize)
+			if tt.width == 0 && size(c.Width+1, c.HistoryLength) <= budget {
+				t.Errorf("width %d leaves room for a wider sketch", c.Width)
+			}
+			if tt.history == 0 && c.HistoryLength < ticks && size(c.Width, c.HistoryLength+1) <= budget {
+				t.Errorf("bucket history %d leaves room for more", c.HistoryLength)
+			}
+		})
+	}
+}
+
+func TestSampleCardinality(t *testing.T) {
+	start := time.Date(2024, 1, 22, 3, 0, 0, 0, time.UTC)
+	// accessLog returns lines for items at one per second from the start.
+	accessLog := func(items []string) []string {
+		lines := make([]string, len(items))
+		for i, item := range items {
+			ts := start.Add(time.Duration(i) * time.Second).Format(accessLogLayout)
+			lines[i] = fmt.Sprintf("%s - - [%s] \"GET / HTTP/1.1\" 200 1", item, ts)
+		}
+		return lines
+	}
+	distinct := func(n, of int, format string) []string {
+		items := make([]string, n)
+		for i := range items {
+			items[i] = fmt.Sprintf(format, i%of)
+		}
+		return items
+	}
+	tests := []struct {
+		name  string
+		set   func(c *Config)
+		lines []string
+		want  uint64
+	}{
+		{
+			name:  "text",
+			lines: distinct(5000, 1000, "item-%d"),
+			want:  1000,
+		},
+		{
+			name:  "normalized text",
+			set:   func(c *Config) { c.Normalize = stringList{"lower"} },
+			lines: append(distinct(500, 500, "item-%d"), distinct(500, 500, "ITEM-%d")...),
+			want:  500,
+		},
+		{
+			// 500 items in the first hour, others after.
+			name:  "access log, one window",
+			set:   func(c *Config) { c.AccessLog = true },
+			lines: accessLog(append(distinct(3600, 500, "10.0.%d"), distinct(3600, 3600, "10.1.%d")...)),
+			want:  501, // the first record of the next hour ends the sample
+		},
+		{
+			name:  "json",
+			set:   func(c *Config) { c.JSON = true },
+			lines: distinct(1000, 200, `{"item": "item-%d", "timestamp": 1}`),
+			want:  200,
+		},
+		{
+			name: "access log shorter than a window",
+			set: func(c *Config) {
+				c.AccessLog = true
+				c.WindowSize = 24 * time.Hour
+			},
+			lines: accessLog(distinct(3600, 300, "10.0.%d")),
+			want:  300, // the whole file
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := config
+			c.WindowSize = time.Hour
+			c.TimestampLayout = accessLogLayout
+			c.AccessLog, c.JSON, c.Normalize = false, false, nil
+			if tt.set != nil {
+				tt.set(&c)
+			}
+			c.InputPath = writeSample(t, tt.lines)
+			got, ok := sampleCardinality(&c)
+			if !ok {
+				t.Fatal("no estimate")
+			}
+			if math.Abs(float64(got)-float64(tt.want)) > 0.02*float64(tt.want) {
+				t.Errorf("estimate %d, want %d within 2%%", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("no estimate", func(t *testing.T) {
+		c := config
+		c.WindowSize = time.Hour
+		c.AccessLog, c.JSON, c.Normalize = false, false, nil
+		if _, ok := sampleCardinality(&c); ok {
+			t.Error("estimate without an input file")
+		}
+		c.InputPath = filepath.Join(t.TempDir(), "missing")
+		if _, ok := sampleCardinality(&c); ok {
+			t.Error("estimate from a missing file")
+		}
+		// Text has no event time, so only the whole file will do.
+		c.InputPath = writeSample(t, distinct(sizingSampleBytes/8, 1000, "item-%03d"))
+		if _, ok := sampleCardinality(&c); ok {
+			t.Error("estimate from part of a file without event time")
+		}
+	})
+}
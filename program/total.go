@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/keilerkonzept/topk/heap"
+)
+
+// windowTotal counts all records in the window, per tick, on the same clock
+// as the sketch. Guarded by the model's sketchMu.
+type windowTotal struct {
+	ticks []uint64
+	head  int
+	sum   uint64
+}
+
+func newWindowTotal(ticks int) *windowTotal {
+	return &windowTotal{ticks: make([]uint64, max(1, ticks))}
+}
+
+func (w *windowTotal) add(n uint32) {
+	w.ticks[w.head] += uint64(n)
+	w.sum += uint64(n)
+}
+
+// addAt counts n records in the tick age ticks before the current one.
+func (w *windowTotal) addAt(n uint32, age int) {
+	if age >= len(w.ticks) {
+		return
+	}
+	w.ticks[(w.head-age+len(w.ticks))%len(w.ticks)] += uint64(n)
+	w.sum += uint64(n)
+}
+
+// tick advances the window by n ticks, expiring the oldest.
+func (w *windowTotal) tick(n int) {
+	for range min(n, len(w.ticks)) {
+		w.head = (w.head + 1) % len(w.ticks)
+		w.sum -= w.ticks[w.head]
+		w.ticks[w.head] = 0
+	}
+}
+
+func (w *windowTotal) reset() {
+	clear(w.ticks)
+	w.sum = 0
+}
+
+// windowTotalCount returns the number of records in the window.
+func (m *model) windowTotalCount() uint64 {
+	m.sketchMu.Lock()
+	defer m.sketchMu.Unlock()
+	return m.total.sum
+}
+
+// shares returns each item's percentage of total records, and the
+// cumulative percentage of it and all items ranked above it by count. Counts
+// are sketch estimates, so percentages are capped at 100.
+func shares(items []heap.Item, total uint64) (share, cumulative map[string]float64) {
+	share = make(map[string]float64, len(items))
+	cumulative = make(map[string]float64, len(items))
+	if total == 0 {
+		return share, cumulative
+	}
+	ranked := cloneItems(items)
+	// The list may be ordered by rise.
+	insertionSort(ranked)
+	var sum uint64
+	for _, item := range ranked {
+		sum += uint64(item.Count)
+		share[item.Item] = min(100, 100*float64(item.Count)/float64(total))
+		cumulative[item.Item] = min(100, 100*float64(sum)/float64(total))
+	}
+	return share, cumulative
+}
+
+// formatShare renders an item's share of the window for the list.
+func formatShare(share, cumulative float64) string {
+	return fmt.Sprintf("%.1f%% (Σ %.1f%%)", share, cumulative)
+}
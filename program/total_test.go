@@ -0,0 +1,110 @@
+package main
+
+import (
+	"maps"
+	"testing"
+
+	"github.com/keilerkonzept/topk/heap"
+)
+
+func TestWindowTotal(t *testing.T) {
+	w := newWindowTotal(3)
+	steps := []struct {
+		name string
+		op   func()
+		want uint64
+	}{
+		{"add", func() { w.add(5) }, 5},
+		{"tick", func() { w.tick(1) }, 5},
+		{"add after a tick", func() { w.add(2) }, 7},
+		{"add to the previous tick", func() { w.addAt(4, 1) }, 11},
+		{"add beyond the window", func() { w.addAt(9, 3) }, 11},
+		{"tick by nothing", func() { w.tick(0) }, 11},
+		{"tick", func() { w.tick(1) }, 11},
+		// The first tick held 5+4.
+		{"expire the first tick", func() { w.tick(1) }, 2},
+		{"add to the current tick", func() { w.addAt(1, 0) }, 3},
+		{"tick past the window", func() { w.tick(5) }, 0},
+		{"add after expiring all", func() { w.add(6) }, 6},
+		{"reset", func() { w.reset() }, 0},
+		{"add after a reset", func() { w.add(1) }, 1},
+	}
+	for _, s := range steps {
+		s.op()
+		if w.sum != s.want {
+			t.Fatalf("%s: sum = %d, want %d", s.name, w.sum, s.want)
+		}
+		var sum uint64
+		for _, n := range w.ticks {
+			sum += n
+		}
+		if sum != w.sum {
+			t.Fatalf("%s: ticks %v add up to %d, sum is %d", s.name, w.ticks, sum, w.sum)
+		}
+	}
+}
+
+func TestShares(t *testing.T) {
+	tests := []struct {
+		name                 string
+		items                []heap.Item
+		total                uint64
+		wantShare, wantCumul map[string]float64
+	}{
+		{
+			name:      "no records",
+			items:     []heap.Item{{Item: "a", Count: 3}},
+			total:     0,
+			wantShare: map[string]float64{},
+			wantCumul: map[string]float64{},
+		},
+		{
+			name: "ordered by rise",
+			// Σ follows the count order a, b, c.
+			items:     []heap.Item{{Item: "c", Count: 10}, {Item: "a", Count: 50}, {Item: "b", Count: 40}},
+			total:     200,
+			wantShare: map[string]float64{"a": 25, "b": 20, "c": 5},
+			wantCumul: map[string]float64{"a": 25, "b": 45, "c": 50},
+		},
+		{
+			name:      "overestimates",
+			items:     []heap.Item{{Item: "a", Count: 150}, {Item: "b", Count: 100}},
+			total:     200,
+			wantShare: map[string]float64{"a": 75, "b": 50},
+			wantCumul: map[string]float64{"a": 75, "b": 100},
+		},
+		{
+			name:      "estimate over the total",
+			items:     []heap.Item{{Item: "a", Count: 300}},
+			total:     200,
+			wantShare: map[string]float64{"a": 100},
+			wantCumul: map[string]float64{"a": 100},
+		},
+	}
+	for _, tt := range tests {
+		share, cumul := shares(tt.items, tt.total)
+		if !maps.Equal(share, tt.wantShare) {
+			t.Errorf("%s: shares = %v, want %v", tt.name, share, tt.wantShare)
+		}
+		if !maps.Equal(cumul, tt.wantCumul) {
+			t.Errorf("%s: cumulative = %v, want %v", tt.name, cumul, tt.wantCumul)
+		}
+	}
+}
+
+func TestFormatShare(t *testing.T) {
+	tests := []struct {
+		share, cumulative float64
+		want              string
+	}{
+		{25, 45, "25.0% (Σ 45.0%)"},
+		{0, 0, "0.0% (Σ 0.0%)"},
+		{0.04, 99.96, "0.0% (Σ 100.0%)"},
+		{100, 100, "100.0% (Σ 100.0%)"},
+	}
+	for _, tt := range tests {
+		if got := formatShare(tt.share, tt.cumulative); got != tt.want {
+			t.Errorf("formatShare(%v, %v) = %q, want %q", tt.share, tt.cumulative, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/keilerkonzept/topk/heap"
+)
+
+// List orders.
+const (
+	sortByCount = "count"
+	sortByRise  = "rise"
+)
+
+// trend scores an item's recent rate against its own baseline: the mean
+// count of the last -trend-ticks ticks as a z-score over the per-tick counts
+// in the rest of the window.
+type trend struct {
+	score float64
+	isNew bool // no counts before the recent ticks
+}
+
+// badge marks new entrants and items rising faster than -rise-threshold.
+func (t trend) badge() string {
+	switch {
+	case t.isNew:
+		return "NEW"
+	case t.score >= config.RiseThreshold:
+		return fmt.Sprintf("↑%.1fσ", t.score)
+	}
+	return ""
+}
+
+// scoreTrend scores a series of per-bucket counts, oldest first, of which
+// only the last observed entries have been filled since the window started.
+func scoreTrend(series []float64, recent, observed int) trend {
+	observed = min(observed, len(series))
+	if recent < 1 || observed < 2*recent {
+		// Not enough baseline yet.
+		return trend{}
+	}
+	base := series[len(series)-observed : len(series)-recent]
+	last := series[len(series)-recent:]
+
+	var baseSum, baseSq, lastSum float64
+	for _, v := range base {
+		baseSum += v
+		baseSq += v * v
+	}
+	for _, v := range last {
+		lastSum += v
+	}
+	n := float64(len(base))
+	baseMean := baseSum / n
+	lastMean := lastSum / float64(len(last))
+	// Counts are at least Poisson-noisy, and a flat baseline must not make
+	// every change infinitely significant.
+	sd := math.Sqrt(max(baseSq/n-baseMean*baseMean, baseMean, 1))
+	return trend{
+		score: (lastMean - baseMean) / (sd / math.Sqrt(float64(len(last)))),
+		isNew: baseSum == 0 && lastSum > 0,
+	}
+}
+
+// updateTrends scores every listed item from its bucket history and, when
+// sorting by rise, reorders the list.
+func (m *model) updateTrends() {
+	m.mu.Lock()
+	items := cloneItems(m.listItems)
+	latest, start := m.latestTick, m.startTick
+	m.mu.Unlock()
+
+	trends := make(map[string]trend, len(items))
+	m.sketchMu.Lock()
+	history, window := m.sketch.BucketHistoryLength, m.sketch.WindowSize
+	series := make([]float64, history)
+	// Bucket history entries span window/history ticks each.
+	recent := max(1, config.TrendTicks*history/window)
+	observed := history
+	if !start.IsZero() {
+		observed = int(latest.Sub(start)/config.TickSize)*history/window + 1
+	}
+	for _, item := range items {
+		fillSeriesFromSketch(m.sketch, item, series, false)
+		trends[item.Item] = scoreTrend(series, recent, observed)
+	}
+	m.sketchMu.Unlock()
+
+	if m.sortBy == sortByRise {
+		sort.SliceStable(items, func(i, j int) bool {
+			return trends[items[i].Item].score > trends[items[j].Item].score
+		})
+	} else {
+		insertionSort(items)
+	}
+	m.mu.Lock()
+	m.trends = trends
+	m.listItems = items
+	m.mu.Unlock()
+}
+
+// setLatestTickLocked records the current tick, with m.mu held. The first tick
+// after startup or a reset starts the trend baseline.
+func (m *model) setLatestTickLocked(t time.Time) {
+	if m.startTick.IsZero() {
+		m.startTick = t
+	}
+	m.latestTick = t
+}
+
+func (m *model) toggleSort() {
+	if m.sortBy == sortByRise {
+		m.sortBy = sortByCount
+	} else {
+		m.sortBy = sortByRise
+	}
+}
+
+// topItem returns the item with the highest count, whatever the list order.
+func topItem(items []heap.Item) (heap.Item, bool) {
+	if len(items) == 0 {
+		return heap.Item{}, false
+	}
+	top := items[0]
+	for _, it := range items[1:] {
+		if less(it, top) {
+			top = it
+		}
+	}
+	return top, true
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"math"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestScoreTrend(t *testing.T) {
+	tests := []struct {
+		name             string
+		series           []float64
+		recent, observed int
+		want             trend
+	}{
+		{"no recent ticks", []float64{1, 2, 3, 4}, 0, 4, trend{}},
+		{"fewer ticks than -trend-ticks", []float64{0, 0, 0, 0, 0, 0, 0, 0, 9, 9}, 3, 2, trend{}},
+		{"fewer ticks than twice -trend-ticks", []float64{0, 0, 0, 0, 0, 1, 1, 1, 9, 9}, 3, 5, trend{}},
+		// sd = sqrt(max(0, 4, 1)) = 2; (10-4) / (2/√2).
+		{"flat baseline", []float64{4, 4, 4, 4, 10, 10}, 2, 6, trend{score: 3 * math.Sqrt2}},
+		// sd = 1; 2 / (1/√2).
+		{"new", []float64{0, 0, 0, 0, 3, 1}, 2, 6, trend{score: 2 * math.Sqrt2, isNew: true}},
+		{"never seen", []float64{0, 0, 0, 0, 0, 0}, 2, 6, trend{}},
+		// Variance 20-16 = 4, the same as the mean: sd = 2; (8-4) / 2.
+		{"noisy baseline", []float64{2, 6, 2, 6, 8}, 1, 5, trend{score: 2}},
+		// Variance 50-25 = 25 is over the mean: sd = 5; (15-5) / 5.
+		{"noisier baseline", []float64{0, 10, 0, 10, 15}, 1, 5, trend{score: 2}},
+		// sd = sqrt(10); (2-10) / (sqrt(10)/√2).
+		{"falling", []float64{10, 10, 2, 2}, 2, 4, trend{score: -8 / math.Sqrt(5)}},
+		// Only the last 6 buckets were filled since the start: the baseline
+		// is 1, 1, 1, 1.
+		{"partly observed", []float64{9, 9, 9, 1, 1, 1, 1, 5, 5}, 2, 6, trend{score: 4 * math.Sqrt2}},
+		{"observed beyond the series", []float64{1, 1, 3, 3}, 2, 100, trend{score: 2 * math.Sqrt2}},
+	}
+	for _, tt := range tests {
+		got := scoreTrend(tt.series, tt.recent, tt.observed)
+		if got.isNew != tt.want.isNew || math.Abs(got.score-tt.want.score) > 1e-9 {
+			t.Errorf("%s: scoreTrend(%v, %d, %d) = %+v, want %+v", tt.name, tt.series, tt.recent, tt.observed, got, tt.want)
+		}
+	}
+}
+
+func TestTrendBadge(t *testing.T) {
+	setConfig(t, func(c *Config) { c.RiseThreshold = 3 })
+	tests := []struct {
+		trend trend
+		want  string
+	}{
+		{trend{}, ""},
+		{trend{score: 2.99}, ""},
+		{trend{score: 3}, "↑3.0σ"},
+		{trend{score: 4.26}, "↑4.3σ"},
+		{trend{score: -5}, ""},
+		// New entrants are marked whatever their score.
+		{trend{score: 0.5, isNew: true}, "NEW"},
+		{trend{score: 9, isNew: true}, "NEW"},
+	}
+	for _, tt := range tests {
+		if got := tt.trend.badge(); got != tt.want {
+			t.Errorf("%+v.badge() = %q, want %q", tt.trend, got, tt.want)
+		}
+	}
+}
+
+func TestSortByRise(t *testing.T) {
+	setConfig(t, func(c *Config) {
+		c.K = 10
+		c.Width, c.Depth = 1024, 3
+		c.TickSize = time.Minute
+		c.WindowSize = 20 * time.Minute
+		c.HistoryLength = 20
+		c.TrendTicks = 3
+		c.RiseThreshold = 3
+		c.AllowedLateness = 0
+		c.Replay = false
+		c.Sort = sortByCount
+	})
+	m := newModel(newSketch(&config))
+	clock := m.newEventClock()
+	start := time.Date(2024, 1, 22, 3, 0, 0, 0, time.UTC)
+	perMinute := func(minute int) map[string]int {
+		if minute < 17 {
+			return map[string]int{"steady-6": 6, "steady-5": 5, "rising": 1}
+		}
+		return map[string]int{"steady-6": 6, "steady-5": 5, "rising": 10, "new": 3}
+	}
+	for minute := range 20 {
+		for item, n := range perMinute(minute) {
+			for i := range n {
+				m.countEvent(clock, start.Add(time.Duration(minute)*time.Minute+time.Duration(i)*time.Second), item, 1)
+			}
+		}
+	}
+	m.drainEvents(clock)
+	m.flushIngest()
+	m.sketchMu.Lock()
+	m.listItems = m.sketch.SortedSlice()
+	m.sketchMu.Unlock()
+
+	names := func() []string {
+		var s []string
+		for _, it := range m.listItems {
+			s = append(s, it.Item)
+		}
+		return s
+	}
+	m.updateTrends()
+	// Totals 120, 100, 47 and 9.
+	if got, want := names(), []string{"steady-6", "steady-5", "rising", "new"}; !slices.Equal(got, want) {
+		t.Errorf("by count: %v, want %v", got, want)
+	}
+
+	m.toggleSort()
+	m.updateTrends()
+	// rising scores (10-1)·√3, new 3·√3, and the steady items 0, which keep
+	// their count order.
+	if got, want := names(), []string{"rising", "new", "steady-6", "steady-5"}; !slices.Equal(got, want) {
+		t.Errorf("by rise: %v, want %v", got, want)
+	}
+	badges := map[string]string{"rising": "↑15.6σ", "new": "NEW", "steady-6": "", "steady-5": ""}
+	for item, want := range badges {
+		if got := m.trends[item].badge(); got != want {
+			t.Errorf("badge of %s = %q (%+v), want %q", item, got, m.trends[item], want)
+		}
+	}
+}
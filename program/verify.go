@@ -0,0 +1,292 @@
+package main
+
+import (
+	stdheap "container/heap"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/keilerkonzept/topk/heap"
+)
+
+// exactWindow keeps exact per-item counts over the same sliding window as the
+// sketch: one count map per tick plus the running totals.
+//
+// It has its own lock rather than the sketch's, so measuring a refresh does
+// not hold up rendering.
+type exactWindow struct {
+	mu      sync.Mutex
+	totals  map[string]exactTotal
+	buckets []map[string]uint32
+	head    int
+}
+
+// exactTotal is an item's count in the window, with the copy of its name
+// that the maps are keyed by.
+type exactTotal struct {
+	item  string
+	count uint32
+}
+
+func newExactWindow(ticks int) *exactWindow {
+	w := &exactWindow{
+		totals:  make(map[string]exactTotal),
+		buckets: make([]map[string]uint32, max(1, ticks)),
+	}
+	for i := range w.buckets {
+		w.buckets[i] = make(map[string]uint32)
+	}
+	return w
+}
+
+func (w *exactWindow) add(item string, n uint32) {
+	w.mu.Lock()
+	w.addLocked(item, n)
+	w.mu.Unlock()
+}
+
+// addLocked is add with w.mu held. item may be a temporary view (see
+// unsafeString): it is copied only the first time it enters the window.
+func (w *exactWindow) addLocked(item string, n uint32) {
+	item = w.countLocked(item, n)
+	w.buckets[w.head][item] += n
+}
+
+// addAt counts item in the bucket age ticks before the current one. item may
+// be a temporary view.
+func (w *exactWindow) addAt(item string, n uint32, age int) {
+	if age >= len(w.buckets) {
+		return
+	}
+	w.mu.Lock()
+	item = w.countLocked(item, n)
+	w.buckets[(w.head-age+len(w.buckets))%len(w.buckets)][item] += n
+	w.mu.Unlock()
+}
+
+// countLocked adds n to item's total and returns the window's own copy of
+// the name. Storing under item itself would keep the view, since assigning
+// to an existing string key replaces the stored key.
+func (w *exactWindow) countLocked(item string, n uint32) string {
+	t, ok := w.totals[item]
+	if !ok {
+		t.item = strings.Clone(item)
+	}
+	t.count += n
+	w.totals[t.item] = t
+	return t.item
+}
+
+// tick advances the window by n ticks, expiring the oldest buckets.
+func (w *exactWindow) tick(n int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for range min(n, len(w.buckets)) {
+		w.head = (w.head + 1) % len(w.buckets)
+		for item, c := range w.buckets[w.head] {
+			if t := w.totals[item]; t.count > c {
+				t.count -= c
+				w.totals[item] = t
+			} else {
+				delete(w.totals, item)
+			}
+		}
+		clear(w.buckets[w.head])
+	}
+}
+
+func (w *exactWindow) reset() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	clear(w.totals)
+	for _, b := range w.buckets {
+		clear(b)
+	}
+}
+
+// measure compares a sketch ranking against the exact top k of the window.
+func (w *exactWindow) measure(approx []heap.Item, k int) accuracy {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return measureAccuracy(approx, w.topKLocked(k), func(item string) uint32 { return w.totals[item].count })
+}
+
+// topKLocked returns the k largest exact counts, sorted like the sketch's
+// ranking. A min-heap of the best k so far keeps this O(n log k) in the
+// number of distinct items, without copying them all. Called with w.mu held.
+func (w *exactWindow) topKLocked(k int) []heap.Item {
+	if k < 1 {
+		return nil
+	}
+	h := make(worstFirst, 0, k)
+	for _, t := range w.totals {
+		it := heap.Item{Item: t.item, Count: t.count}
+		switch {
+		case len(h) < k:
+			stdheap.Push(&h, it)
+		case less(it, h[0]):
+			h[0] = it
+			stdheap.Fix(&h, 0)
+		}
+	}
+	sort.Slice(h, func(i, j int) bool { return less(h[i], h[j]) })
+	return h
+}
+
+// worstFirst is a container/heap of items with the lowest ranked on top.
+type worstFirst []heap.Item
+
+func (h worstFirst) Len() int           { return len(h) }
+func (h worstFirst) Less(i, j int) bool { return less(h[j], h[i]) }
+func (h worstFirst) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *worstFirst) Push(x any)        { *h = append(*h, x.(heap.Item)) }
+func (h *worstFirst) Pop() any {
+	old := *h
+	it := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return it
+}
+
+// accuracy compares one sketch ranking against the exact ranking.
+type accuracy struct {
+	precision  float64 // share of reported items that are in the exact top-K
+	recall     float64 // share of the exact top-K that was reported
+	rankCorr   float64 // Spearman correlation of ranks of the common items
+	meanRelErr float64 // mean relative count error of reported items
+	maxRelErr  float64 // max relative count error of reported items
+}
+
+func measureAccuracy(approx, exact []heap.Item, exactCount func(string) uint32) accuracy {
+	var a accuracy
+	exactRank := make(map[string]int, len(exact))
+	for i, it := range exact {
+		exactRank[it.Item] = i
+	}
+	var common []int // exact ranks of common items, in approx order
+	for _, it := range approx {
+		if r, ok := exactRank[it.Item]; ok {
+			common = append(common, r)
+		}
+		truth := float64(exactCount(it.Item))
+		relErr := math.Abs(float64(it.Count)-truth) / max(1, truth)
+		a.meanRelErr += relErr
+		a.maxRelErr = max(a.maxRelErr, relErr)
+	}
+	if len(approx) > 0 {
+		a.precision = float64(len(common)) / float64(len(approx))
+		a.meanRelErr /= float64(len(approx))
+	} else if len(exact) == 0 {
+		a.precision = 1
+	}
+	if len(exact) > 0 {
+		a.recall = float64(len(common)) / float64(len(exact))
+	} else {
+		a.recall = 1
+	}
+	a.rankCorr = spearman(common)
+	return a
+}
+
+// spearman returns the rank correlation between the order of ranks and their
+// sorted order.
+func spearman(ranks []int) float64 {
+	n := len(ranks)
+	if n < 2 {
+		return 1
+	}
+	sorted := append([]int(nil), ranks...)
+	sort.Ints(sorted)
+	pos := make(map[int]int, n)
+	for i, r := range sorted {
+		pos[r] = i
+	}
+	var d2 float64
+	for i, r := range ranks {
+		d := float64(i - pos[r])
+		d2 += d * d
+	}
+	nf := float64(n)
+	return 1 - 6*d2/(nf*(nf*nf-1))
+}
+
+// verifier keeps an exact window alongside the sketch and aggregates the
+// accuracy of each refresh.
+type verifier struct {
+	exact *exactWindow
+
+	mu      sync.Mutex
+	last    accuracy
+	sum     accuracy
+	worst   accuracy
+	samples int
+}
+
+func newVerifier(ticks int) *verifier {
+	return &verifier{
+		exact: newExactWindow(ticks),
+		worst: accuracy{precision: 1, recall: 1, rankCorr: 1},
+	}
+}
+
+func (v *verifier) observe(a accuracy) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.last = a
+	v.samples++
+	v.sum.precision += a.precision
+	v.sum.recall += a.recall
+	v.sum.rankCorr += a.rankCorr
+	v.sum.meanRelErr += a.meanRelErr
+	v.sum.maxRelErr += a.maxRelErr
+	v.worst.precision = min(v.worst.precision, a.precision)
+	v.worst.recall = min(v.worst.recall, a.recall)
+	v.worst.rankCorr = min(v.worst.rankCorr, a.rankCorr)
+	v.worst.meanRelErr = max(v.worst.meanRelErr, a.meanRelErr)
+	v.worst.maxRelErr = max(v.worst.maxRelErr, a.maxRelErr)
+}
+
+func (v *verifier) latest() (accuracy, int) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.last, v.samples
+}
+
+func (a accuracy) String() string {
+	return fmt.Sprintf("precision@K %.2f, recall@K %.2f, rank corr %.2f, count err mean %.1f%% max %.1f%%",
+		a.precision, a.recall, a.rankCorr, 100*a.meanRelErr, 100*a.maxRelErr)
+}
+
+// mean averages the accuracy over all refreshes.
+func (v *verifier) mean() accuracy {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.samples == 0 {
+		return accuracy{}
+	}
+	n := float64(v.samples)
+	return accuracy{
+		precision:  v.sum.precision / n,
+		recall:     v.sum.recall / n,
+		rankCorr:   v.sum.rankCorr / n,
+		meanRelErr: v.sum.meanRelErr / n,
+		maxRelErr:  v.sum.maxRelErr / n,
+	}
+}
+
+// report summarizes all refreshes for the end-of-run output.
+func (v *verifier) report() string {
+	mean := v.mean()
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.samples == 0 {
+		return "verify: no refreshes measured"
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "verify: %d refreshes (k=%d width=%d depth=%d decay=%g)\n", v.samples, config.K, config.Width, config.Depth, config.Decay)
+	fmt.Fprintf(&sb, "  mean:  %s\n", mean)
+	fmt.Fprintf(&sb, "  worst: %s\n", v.worst)
+	fmt.Fprintf(&sb, "  last:  %s", v.last)
+	return sb.String()
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"math"
+	"slices"
+	"testing"
+
+	"github.com/keilerkonzept/topk/heap"
+)
+
+func TestSpearman(t *testing.T) {
+	tests := []struct {
+		ranks []int
+		want  float64
+	}{
+		{nil, 1},
+		{[]int{4}, 1},
+		{[]int{0, 1, 2, 3}, 1},
+		{[]int{3, 2, 1, 0}, -1}, // Σd² = 9+1+1+9 = 20; 1 - 6·20/(4·15)
+		{[]int{1, 0, 2}, 0.5},   // Σd² = 2; 1 - 6·2/(3·8)
+		{[]int{7, 2, 9}, 0.5},   // only the order of the ranks counts
+		{[]int{1, 0}, -1},
+	}
+	for _, tt := range tests {
+		if got := spearman(tt.ranks); math.Abs(got-tt.want) > 1e-12 {
+			t.Errorf("spearman(%v) = %v, want %v", tt.ranks, got, tt.want)
+		}
+	}
+}
+
+func TestMeasureAccuracy(t *testing.T) {
+	items := func(kv ...any) []heap.Item {
+		var out []heap.Item
+		for i := 0; i < len(kv); i += 2 {
+			out = append(out, heap.Item{Item: kv[i].(string), Count: uint32(kv[i+1].(int))})
+		}
+		return out
+	}
+	tests := []struct {
+		name          string
+		approx, exact []heap.Item
+		counts        map[string]uint32 // exact window counts, beyond the top-K
+		want          accuracy
+	}{
+		{
+			name:   "exact",
+			approx: items("a", 10, "b", 5, "c", 1),
+			exact:  items("a", 10, "b", 5, "c", 1),
+			want:   accuracy{precision: 1, recall: 1, rankCorr: 1},
+		},
+		{
+			name:   "overestimates",
+			approx: items("a", 12, "b", 5, "c", 3),
+			exact:  items("a", 10, "b", 5, "c", 2),
+			// Relative errors 0.2, 0 and 0.5.
+			want: accuracy{precision: 1, recall: 1, rankCorr: 1, meanRelErr: 0.7 / 3, maxRelErr: 0.5},
+		},
+		{
+			name:   "swapped",
+			approx: items("b", 6, "a", 10),
+			exact:  items("a", 10, "b", 5),
+			want:   accuracy{precision: 1, recall: 1, rankCorr: -1, meanRelErr: 0.1, maxRelErr: 0.2},
+		},
+		{
+			name:   "misses",
+			approx: items("a", 9, "c", 5, "e", 4, "f", 4),
+			exact:  items("a", 9, "b", 7, "c", 5, "d", 5),
+			counts: map[string]uint32{"e": 4, "f": 2},
+			// f: |4-2|/2 = 1.
+			want: accuracy{precision: 0.5, recall: 0.5, rankCorr: 1, meanRelErr: 0.25, maxRelErr: 1},
+		},
+		{
+			name:   "reported item not in the window",
+			approx: items("a", 9, "x", 3),
+			exact:  items("a", 9),
+			// x: |3-0|/max(1, 0) = 3.
+			want: accuracy{precision: 0.5, recall: 1, rankCorr: 1, meanRelErr: 1.5, maxRelErr: 3},
+		},
+		{
+			name: "both empty",
+			want: accuracy{precision: 1, recall: 1, rankCorr: 1},
+		},
+		{
+			name:  "nothing reported",
+			exact: items("a", 1),
+			want:  accuracy{precision: 0, recall: 0, rankCorr: 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			counts := make(map[string]uint32)
+			for _, it := range tt.exact {
+				counts[it.Item] = it.Count
+			}
+			for item, n := range tt.counts {
+				counts[item] = n
+			}
+			got := measureAccuracy(tt.approx, tt.exact, func(item string) uint32 { return counts[item] })
+			for _, f := range []struct {
+				name      string
+				got, want float64
+			}{
+				{"precision", got.precision, tt.want.precision},
+				{"recall", got.recall, tt.want.recall},
+				{"rank correlation", got.rankCorr, tt.want.rankCorr},
+				{"mean relative error", got.meanRelErr, tt.want.meanRelErr},
+				{"max relative error", got.maxRelErr, tt.want.maxRelErr},
+			} {
+				if math.Abs(f.got-f.want) > 1e-12 {
+					t.Errorf("%s = %v, want %v", f.name, f.got, f.want)
+				}
+			}
+		})
+	}
+}
+
+func TestExactWindowTopK(t *testing.T) {
+	w := newExactWindow(2)
+	for _, it := range []struct {
+		item string
+		n    uint32
+	}{{"d", 1}, {"c", 3}, {"a", 5}, {"e", 3}, {"b", 3}} {
+		w.add(it.item, it.n)
+	}
+	tests := []struct {
+		k    int
+		want []string
+	}{
+		{0, nil},
+		{1, []string{"a"}},
+		// Ties rank by name, so the heap must drop e before b and c.
+		{3, []string{"a", "b", "c"}},
+		{4, []string{"a", "b", "c", "e"}},
+		// K above the number of distinct items returns them all.
+		{10, []string{"a", "b", "c", "e", "d"}},
+	}
+	names := func(items []heap.Item) []string {
+		var s []string
+		for _, it := range items {
+			s = append(s, it.Item)
+		}
+		return s
+	}
+	// Map order varies, and with it the order the heap sees items in.
+	for range 20 {
+		w.mu.Lock()
+		for _, tt := range tests {
+			if got := names(w.topKLocked(tt.k)); !slices.Equal(got, tt.want) {
+				t.Errorf("topKLocked(%d) = %v, want %v", tt.k, got, tt.want)
+			}
+		}
+		w.mu.Unlock()
+	}
+
+	// a drops out with its tick; b counts in both.
+	w.tick(1)
+	w.add("b", 4)
+	w.tick(1)
+	w.mu.Lock()
+	got := w.topKLocked(10)
+	w.mu.Unlock()
+	if want := []heap.Item{{Item: "b", Count: 4}}; !slices.Equal(got, want) {
+		t.Errorf("after the first tick expired: %v, want %v", got, want)
+	}
+
+	w.add("c", 2)
+	a := w.measure([]heap.Item{{Item: "b", Count: 4}, {Item: "x", Count: 1}}, 3)
+	if a.precision != 0.5 || a.recall != 0.5 || a.maxRelErr != 1 {
+		t.Errorf("measure against the window = %+v, want precision 0.5, recall 0.5, max error 1", a)
+	}
+}